@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/user/invar/internal/backup"
+	"github.com/user/invar/internal/config"
+	"github.com/user/invar/internal/storage"
+)
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := fs.String("o", "", "write the archive to this file")
+	dir := fs.String("dir", "", "write the archive to this directory (default from config)")
+	keep := fs.Int("keep", -1, "keep only the newest N archives in the directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar backup [-o file] [-dir dir] [-keep N]")
+	}
+
+	cfg, loc, err := resolveLocation()
+	if err != nil {
+		return err
+	}
+	store, err := newStore(loc.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		*dir = cfg.BackupDir()
+	}
+	if *keep < 0 {
+		*keep = cfg.Backup.Keep
+	}
+	filename := *output
+	if filename == "" {
+		filename = filepath.Join(*dir, backup.Name(loc.Profile, time.Now()))
+	}
+
+	m, err := backup.WriteFile(filename, store, loc.Profile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %d tasks to %s\n", m.Tasks(), filename)
+
+	if *output == "" && *keep > 0 {
+		removed, err := backup.Rotate(*dir, loc.Profile, *keep)
+		if err != nil {
+			return err
+		}
+		for _, name := range removed {
+			fmt.Println("Removed old backup", name)
+		}
+	}
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	check := fs.Bool("check", false, "only validate the archive")
+	force := fs.Bool("force", false, "replace a data dir that is not empty (it is kept as <dir>.bak-<time>)")
+	newProfile := fs.String("new-profile", "", "restore into a new profile with this name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: invar restore [-check] [-force] [-new-profile name] <archive>")
+	}
+
+	a, err := backup.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	m := a.Manifest
+	fmt.Printf("Backup of %s taken %s: %d tasks, %s format", m.DataDir,
+		m.CreatedAt.Local().Format("2006-01-02 15:04"), m.Tasks(), m.Format)
+	if m.Encrypted {
+		fmt.Print(", encrypted")
+	}
+	fmt.Println()
+	if *check {
+		fmt.Println("Archive is valid")
+		return nil
+	}
+
+	cfg, loc, err := resolveLocation()
+	if err != nil {
+		return err
+	}
+	if *newProfile != "" {
+		if err := cfg.AddProfile(*newProfile); err != nil {
+			return err
+		}
+		if loc, err = cfg.Location(*newProfile); err != nil {
+			return err
+		}
+	}
+
+	moved, err := a.Restore(loc.DataDir, *force)
+	if errors.Is(err, backup.ErrNotEmpty) {
+		return fmt.Errorf("%s is not empty (use -force to replace it, or -new-profile)", loc.DataDir)
+	}
+	if err != nil {
+		return err
+	}
+	if moved != "" {
+		fmt.Println("Previous data moved to", moved)
+	}
+	if *newProfile != "" {
+		if err := cfg.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Created profile %q\n", *newProfile)
+	}
+	fmt.Println("Restored to", loc.DataDir)
+	return nil
+}
+
+// autoBackup takes a backup of the selected data dir when the configured
+// interval has passed since the last one.
+func autoBackup(cfg *config.Config, loc config.Location, store *storage.Store) error {
+	if cfg.Backup.IntervalHours <= 0 {
+		return nil
+	}
+	dir := cfg.BackupDir()
+	due, err := backup.Due(dir, loc.Profile, time.Duration(cfg.Backup.IntervalHours)*time.Hour)
+	if err != nil || !due {
+		return err
+	}
+	if _, err := backup.WriteFile(filepath.Join(dir, backup.Name(loc.Profile, time.Now())), store, loc.Profile); err != nil {
+		return err
+	}
+	if cfg.Backup.Keep > 0 {
+		_, err = backup.Rotate(dir, loc.Profile, cfg.Backup.Keep)
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+func runConflicts(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	conflicts, err := store.Conflicts()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		if len(conflicts) == 0 {
+			fmt.Println("No conflicts")
+			return nil
+		}
+		for _, c := range conflicts {
+			fmt.Printf("%s  %s\n", task.ShortID(c.TaskID), c.Conflict)
+		}
+		return nil
+	}
+
+	if args[0] != "resolve" || len(args) != 4 {
+		return errors.New("usage: invar conflicts [list | resolve <id> <field> ours|theirs]")
+	}
+	id, field, side := args[1], args[2], args[3]
+	var matches []storage.Conflict
+	for _, c := range conflicts {
+		if strings.HasPrefix(c.TaskID, id) && c.Field == field {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no %s conflict for task %q", field, id)
+	case 1:
+	default:
+		return fmt.Errorf("task ID %q is ambiguous", id)
+	}
+	if err := store.ResolveConflict(matches[0], side); err != nil {
+		return err
+	}
+	fmt.Printf("Resolved %s of %s with %s\n", field, task.ShortID(matches[0].TaskID), side)
+	return nil
+}
+
+// runMergeDriver is the git merge driver for task files, run by git as
+// "invar merge-driver %O %A %B %P" from the top of the data dir. It writes
+// the merged task over %A and fails if a conflict is left for the user.
+func runMergeDriver(args []string) error {
+	fs := flag.NewFlagSet("merge-driver", flag.ContinueOnError)
+	install := fs.Bool("install", false, "register the driver in the data dir's git config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *install {
+		store, err := openStore()
+		if err != nil {
+			return err
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if err := store.InstallMergeDriver(exe + " merge-driver"); err != nil {
+			return err
+		}
+		fmt.Println("Installed the merge driver in", filepath.Join(store.DataDir(), ".git"))
+		return nil
+	}
+	if fs.NArg() != 4 {
+		return errors.New("usage: invar merge-driver <base> <ours> <theirs> <path>")
+	}
+
+	var files [3][]byte
+	for i, name := range fs.Args()[:3] {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			files[i] = data
+		}
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	store, err := storage.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := unlockStore(store); err != nil {
+		return err
+	}
+	merged, conflicts, err := store.MergeFile(fs.Arg(3), files[0], files[1], files[2])
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fs.Arg(1), merged, 0644); err != nil {
+		return err
+	}
+
+	unresolved := 0
+	for _, c := range conflicts {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", fs.Arg(3), c.Conflict)
+		if !c.Resolved {
+			unresolved++
+		}
+	}
+	if unresolved > 0 {
+		return fmt.Errorf("%s: %d conflicts left", fs.Arg(3), unresolved)
+	}
+	return nil
+}
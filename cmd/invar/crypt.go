@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/user/invar/internal/storage"
+)
+
+// unlockStore loads the key for an encrypted data dir, asking for it if it
+// is not already in the session cache.
+func unlockStore(store *storage.Store) error {
+	if !store.Locked() {
+		return nil
+	}
+	secret, err := readSecret("Passphrase: ", false)
+	if err != nil {
+		return err
+	}
+	if err := store.Unlock(secret); err != nil {
+		return err
+	}
+	cacheKey(store)
+	return nil
+}
+
+// cacheKey remembers the key for the session. Failing to is not fatal,
+// since the store is unlocked either way; the passphrase is just asked for
+// again next time.
+func cacheKey(store *storage.Store) {
+	if err := store.CacheKey(); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: key not cached:", err)
+	}
+}
+
+// readSecret returns the key file contents, INVAR_PASSPHRASE, or a
+// passphrase typed at the terminal, in that order. With confirm set the
+// passphrase has to be typed twice.
+func readSecret(prompt string, confirm bool) ([]byte, error) {
+	keyFile := keyFileFlag
+	if keyFile == "" {
+		keyFile = os.Getenv("INVAR_KEY_FILE")
+	}
+	if keyFile != "" {
+		return os.ReadFile(keyFile)
+	}
+	if pass := os.Getenv("INVAR_PASSPHRASE"); pass != "" {
+		return []byte(pass), nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return nil, errors.New("no passphrase: set INVAR_PASSPHRASE or use -key-file")
+	}
+
+	pass, err := promptPassword(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if len(pass) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := promptPassword("Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(pass, again) {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	return pass, nil
+}
+
+func promptPassword(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	return pass, err
+}
+
+func runEncrypt(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	secret, err := readSecret("New passphrase: ", true)
+	if err != nil {
+		return err
+	}
+	if err := store.EnableEncryption(secret); err != nil {
+		return err
+	}
+	cacheKey(store)
+	fmt.Println("Task files encrypted in", store.DataDir())
+	fmt.Println("Note: earlier plaintext versions are still in the git history.")
+	return nil
+}
+
+func runDecrypt(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if err := store.DisableEncryption(); err != nil {
+		return err
+	}
+	fmt.Println("Task files decrypted in", store.DataDir())
+	return nil
+}
+
+func runUnlock(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if !store.Encrypted() {
+		return errors.New("task data is not encrypted")
+	}
+	fmt.Println("Key cached for this session")
+	return nil
+}
+
+// runLock forgets the cached key. It does not need the key itself, so it
+// resolves the data dir without unlocking.
+func runLock(args []string) error {
+	_, loc, err := resolveLocation()
+	if err != nil {
+		return err
+	}
+	store, err := newStore(loc.DataDir)
+	if err != nil {
+		return err
+	}
+	return store.ForgetKey()
+}
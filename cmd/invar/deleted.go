@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/user/invar/internal/task"
+)
+
+func runDeleted(args []string) error {
+	fs := flag.NewFlagSet("deleted", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar deleted")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	deleted, err := store.Deleted()
+	if err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		fmt.Println("No deleted tasks in the history")
+		return nil
+	}
+	for _, d := range deleted {
+		fmt.Printf("%s  %s  %s\n", task.ShortID(d.Task.ID), d.DeletedAt.Local().Format("2006-01-02 15:04"), firstLine(d.Task.Content))
+	}
+	return nil
+}
+
+func runResurrect(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: invar resurrect <id>")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	deleted, err := store.Deleted()
+	if err != nil {
+		return err
+	}
+	var tasks []*task.Task
+	for _, d := range deleted {
+		tasks = append(tasks, d.Task)
+	}
+	found, err := findTask(tasks, args[0])
+	if err != nil {
+		return err
+	}
+	t, err := store.Resurrect(found.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Resurrected:", firstLine(t.Content))
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runDoctor checks the data dir and its git repo, and with -fix repairs
+// what it can and quarantines files it cannot read.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "repair problems and quarantine unreadable files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	problems, err := store.Check()
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found in", store.DataDir())
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Println("✗", p)
+	}
+	if !*fix {
+		return fmt.Errorf("%d problems found; run invar doctor -fix to repair them", len(problems))
+	}
+
+	actions, err := store.Repair(problems)
+	for _, a := range actions {
+		fmt.Println("✓", a)
+	}
+	if err != nil {
+		return err
+	}
+
+	remaining, err := store.Check()
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		for _, p := range remaining {
+			fmt.Println("✗", p)
+		}
+		return fmt.Errorf("%d problems could not be repaired", len(remaining))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/user/invar/internal/export"
+)
+
+// runExport writes the selected tasks to stdout or a file. It takes the
+// same filters as list.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: "+strings.Join(export.Names(), ", "))
+	output := fs.String("o", "", "write to this file instead of stdout")
+	ff := addFilterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: invar export [-format F] [-o file] [filters]")
+	}
+
+	exporter, err := export.Get(*format)
+	if err != nil {
+		return err
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	tasks, err := ff.load(store)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return exporter.Export(w, tasks)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/user/invar/internal/storage"
+)
+
+// runFormat prints the data dir's file format, or converts every task file
+// when a new format is given.
+func runFormat(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: invar format [json|markdown]")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		fmt.Println(store.Format())
+		return nil
+	}
+
+	f, err := storage.ParseFormat(args[0])
+	if err != nil {
+		return err
+	}
+	if f == store.Format() {
+		fmt.Println("Task files are already", f)
+		return nil
+	}
+	if err := store.SetFormat(f); err != nil {
+		return err
+	}
+	fmt.Println("Task files converted to", f)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/user/invar/internal/storage"
+)
+
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	months := fs.Int("months", 0, "squash history older than this many months (0 only repacks)")
+	by := fs.String("by", "day", "keep one commit per day or per week of squashed history")
+	force := fs.Bool("force", false, "squash even though a remote is configured, replacing its history")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar gc [-months N] [-by day|week] [-force]")
+	}
+
+	var opts storage.GCOptions
+	switch *by {
+	case "day":
+	case "week":
+		opts.Weekly = true
+	default:
+		return fmt.Errorf("invalid -by %q (want day or week)", *by)
+	}
+	if *months < 0 {
+		return errors.New("-months must not be negative")
+	}
+	if *months > 0 {
+		opts.Before = time.Now().AddDate(0, -*months, 0)
+	}
+	opts.Force = *force
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	report, err := store.GC(ctx, opts)
+	if errors.Is(err, storage.ErrHasRemote) {
+		return fmt.Errorf("%w (use -force, then re-clone the other copies)", err)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Pulled {
+		if err := store.Reload(); err != nil {
+			return err
+		}
+	}
+
+	if report.Squashed > 0 {
+		fmt.Printf("Squashed %d commits into %d\n", report.Squashed, report.Into)
+	}
+	if report.Replaced {
+		fmt.Println("Replaced the remote's history; clone the other copies afresh")
+	}
+	if saved := report.SizeBefore - report.SizeAfter; saved > 0 {
+		fmt.Printf("Saved %s (%s → %s)\n", formatSize(saved), formatSize(report.SizeBefore), formatSize(report.SizeAfter))
+	} else {
+		fmt.Printf("Nothing to save (%s)\n", formatSize(report.SizeAfter))
+	}
+	return nil
+}
+
+// formatSize renders a byte count with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	restore := fs.String("restore", "", "restore the version from this commit as a new commit")
+	show := fs.String("show", "", "print the task as it was in this commit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: invar history [-show commit] [-restore commit] <id>")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	id, err := findAnyTask(store, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	versions, err := store.History(id)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("task %s has no committed versions", task.ShortID(id))
+	}
+
+	if *show != "" || *restore != "" {
+		v, err := findVersion(versions, *show+*restore)
+		if err != nil {
+			return err
+		}
+		if *show != "" {
+			if v.Task == nil {
+				return errors.New("this version is the deletion of the task")
+			}
+			printTask(v.Task)
+			return nil
+		}
+		if err := store.RestoreVersion(v); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s to the version from %s\n", task.ShortID(id), v.When.Local().Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	for _, v := range versions {
+		line := fmt.Sprintf("%s  %s  %s", v.Commit[:7], v.When.Local().Format("2006-01-02 15:04"), v.Subject)
+		if len(v.Changes) > 0 {
+			fields := make([]string, len(v.Changes))
+			for i, c := range v.Changes {
+				fields[i] = c.Field
+			}
+			line += "  (" + strings.Join(fields, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// findAnyTask resolves a task ID prefix against active, archived and
+// trashed tasks, then against tasks deleted from the repo.
+func findAnyTask(store *storage.Store, prefix string) (string, error) {
+	var all []*task.Task
+	for _, archived := range []bool{false, true} {
+		tasks, err := store.List(archived)
+		if err != nil {
+			return "", err
+		}
+		all = append(all, tasks...)
+	}
+	trashed, err := store.ListTrash()
+	if err != nil {
+		return "", err
+	}
+	all = append(all, trashed...)
+	if t, err := findTask(all, prefix); err == nil {
+		return t.ID, nil
+	}
+
+	deleted, err := store.Deleted()
+	if err != nil {
+		return "", err
+	}
+	all = all[:0]
+	for _, d := range deleted {
+		all = append(all, d.Task)
+	}
+	t, err := findTask(all, prefix)
+	if err != nil {
+		return "", err
+	}
+	return t.ID, nil
+}
+
+func findVersion(versions []storage.TaskVersion, prefix string) (storage.TaskVersion, error) {
+	var found []storage.TaskVersion
+	for _, v := range versions {
+		if strings.HasPrefix(v.Commit, prefix) {
+			found = append(found, v)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return storage.TaskVersion{}, fmt.Errorf("no version from commit %q", prefix)
+	case 1:
+		return found[0], nil
+	}
+	return storage.TaskVersion{}, fmt.Errorf("commit %q is ambiguous", prefix)
+}
+
+func printTask(t *task.Task) {
+	fmt.Println(t.Content)
+	fmt.Println()
+	fmt.Println("priority: ", t.Priority)
+	if t.Deadline != nil {
+		fmt.Println("deadline: ", task.FormatDeadline(*t.Deadline, "2006-01-02"))
+	}
+	if len(t.Tags) > 0 {
+		fmt.Println("tags:     ", strings.Join(t.Tags, ", "))
+	}
+	if t.CompletedAt != nil {
+		fmt.Println("completed:", t.CompletedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if t.Archived {
+		fmt.Println("archived:  yes")
+	}
+	if t.DeletedAt != nil {
+		fmt.Println("trashed:  ", t.DeletedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
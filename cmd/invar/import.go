@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/user/invar/internal/importer"
+	"github.com/user/invar/internal/task"
+)
+
+const importUsage = "usage: invar import -format todotxt|taskwarrior|csv [-map field=column,...] [-dry-run] <file|->\n       invar import -undo"
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "source format: todotxt, taskwarrior or csv")
+	mapping := fs.String("map", "", "CSV column mapping, e.g. content=Title,deadline=Due")
+	dryRun := fs.Bool("dry-run", false, "show what would be imported without saving")
+	undo := fs.Bool("undo", false, "remove the tasks added by the last import")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	if *undo {
+		n, kept, err := store.UndoImport()
+		for _, t := range kept {
+			fmt.Printf("Kept %s, changed since the import: %s\n", task.ShortID(t.ID), firstLine(t.Content))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d imported tasks\n", n)
+		return nil
+	}
+
+	if *format == "" || fs.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+	m, err := importer.ParseMapping(*mapping)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	incoming, err := importer.Parse(importer.Format(*format), r, m)
+	if err != nil {
+		return err
+	}
+
+	var existing []*task.Task
+	for _, archived := range []bool{false, true} {
+		tasks, err := store.List(archived)
+		if err != nil {
+			return err
+		}
+		existing = append(existing, tasks...)
+	}
+	trashed, err := store.ListTrash()
+	if err != nil {
+		return err
+	}
+	existing = append(existing, trashed...)
+
+	fresh, dups := importer.Dedupe(existing, incoming)
+	for _, t := range dups {
+		fmt.Println("= duplicate, skipped:", firstLine(t.Content))
+	}
+	for _, t := range fresh {
+		fmt.Printf("+ %-6s %s\n", t.Priority, firstLine(t.Content))
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: would import %d tasks, skip %d duplicates\n", len(fresh), len(dups))
+		return nil
+	}
+	if len(fresh) == 0 {
+		fmt.Println("Nothing to import")
+		return nil
+	}
+	if err := store.Import(fresh, *format); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d tasks, skipped %d duplicates (undo with invar import -undo)\n", len(fresh), len(dups))
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/user/invar/internal/date"
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+// filterFlags are the task selection flags shared by list and export.
+type filterFlags struct {
+	archived *bool
+	trash    *bool
+	all      *bool
+	priority *string
+	tags     *string
+	status   *string
+	overdue  *bool
+	due      *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		archived: fs.Bool("archived", false, "select archived tasks instead of active ones"),
+		trash:    fs.Bool("trash", false, "select trashed tasks instead of active ones"),
+		all:      fs.Bool("all", false, "select active and archived tasks"),
+		priority: fs.String("priority", "", "only tasks with this priority (high, medium, low)"),
+		tags:     fs.String("tag", "", "only tasks with all of these comma-separated tags"),
+		status:   fs.String("status", "", "only pending or done tasks"),
+		overdue:  fs.Bool("overdue", false, "only overdue tasks"),
+		due:      fs.String("due", "", "only tasks due before this date (e.g. tomorrow, 2026-11-01)"),
+	}
+}
+
+// load reads the selected tasks from the store and applies the filter,
+// sorted by creation time.
+func (ff *filterFlags) load(store *storage.Store) ([]*task.Task, error) {
+	var f task.Filter
+	switch strings.ToLower(*ff.priority) {
+	case "":
+	case "high", "medium", "low":
+		f.Priority = task.Priority(strings.ToLower(*ff.priority))
+	default:
+		return nil, fmt.Errorf("invalid priority %q", *ff.priority)
+	}
+	if *ff.tags != "" {
+		f.Tags = strings.Split(*ff.tags, ",")
+	}
+	switch *ff.status {
+	case "":
+	case "pending", "done":
+		done := *ff.status == "done"
+		f.Done = &done
+	default:
+		return nil, fmt.Errorf("invalid status %q (want pending or done)", *ff.status)
+	}
+	f.Overdue = *ff.overdue
+	if *ff.due != "" {
+		d, err := date.Parse(*ff.due)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			return nil, fmt.Errorf("invalid date %q", *ff.due)
+		}
+		f.DueBefore = d
+	}
+
+	var tasks []*task.Task
+	switch {
+	case *ff.trash:
+		trashed, err := store.ListTrash()
+		if err != nil {
+			return nil, err
+		}
+		tasks = trashed
+	case *ff.all:
+		for _, archived := range []bool{false, true} {
+			list, err := store.List(archived)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, list...)
+		}
+	default:
+		list, err := store.List(*ff.archived)
+		if err != nil {
+			return nil, err
+		}
+		tasks = list
+	}
+
+	tasks = f.Apply(tasks)
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	return tasks, nil
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	ff := addFilterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar list [filters]")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	tasks, err := ff.load(store)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		check := " "
+		if t.CompletedAt != nil {
+			check = "x"
+		}
+		deadline := ""
+		if t.Deadline != nil {
+			deadline = task.FormatDeadline(*t.Deadline, "2006-01-02")
+		}
+		fmt.Printf("%s  [%s] %-6s  %-16s  %s\n", task.ShortID(t.ID), check, t.Priority, deadline, firstLine(t.Content))
+	}
+	return nil
+}
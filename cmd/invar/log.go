@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/invar/internal/date"
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+func runLog(args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	limit := fs.Int("n", 0, "show at most this many commits")
+	skip := fs.Int("skip", 0, "skip this many commits first")
+	since := fs.String("since", "", "only commits on or after this day (e.g. 2026-10-01, today)")
+	until := fs.String("until", "", "only commits on or before this day")
+	author := fs.String("author", "", "only commits whose author name or email contains this")
+	taskID := fs.String("task", "", "only commits that touched this task")
+	path := fs.String("path", "", "only commits that touched this file or dir")
+	asJSON := fs.Bool("json", false, "print the commits as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar log [-n N] [-skip N] [-since day] [-until day] [-author name] [-task id] [-path path] [-json]")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	opts := storage.LogOptions{}
+	opts.Limit = *limit
+	opts.Skip = *skip
+	opts.Author = *author
+	if *path != "" {
+		opts.Paths = []string{*path}
+	}
+	if *since != "" {
+		if opts.Since, err = parseDay(*since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		day, err := parseDay(*until)
+		if err != nil {
+			return err
+		}
+		opts.Until = day.AddDate(0, 0, 1)
+	}
+	if *taskID != "" {
+		if opts.Task, err = findAnyTask(store, *taskID); err != nil {
+			return err
+		}
+	}
+
+	entries, err := store.Log(opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if entries == nil {
+			entries = []storage.LogEntry{}
+		}
+		return enc.Encode(entries)
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %s  %s\n", e.Hash[:7], e.When.Local().Format("2006-01-02 15:04"), e.Subject())
+		for _, t := range e.Tasks {
+			fmt.Printf("         %-6s  %s\n", t.Type, task.ShortID(t.ID))
+		}
+	}
+	return nil
+}
+
+// parseDay parses a date the way deadlines are entered and returns the
+// start of that day in local time.
+func parseDay(input string) (time.Time, error) {
+	d, err := date.Parse(input)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if d == nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", input)
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.Local), nil
+}
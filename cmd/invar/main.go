@@ -1,34 +1,97 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/user/invar/internal/app"
+	"github.com/user/invar/internal/config"
 	"github.com/user/invar/internal/storage"
 	"github.com/user/invar/internal/task"
 )
 
+// commands maps subcommand names to their handlers. Running invar without
+// a subcommand launches the TUI.
+var commands = map[string]func(args []string) error{
+	"trash":        runTrash,
+	"encrypt":      runEncrypt,
+	"decrypt":      runDecrypt,
+	"unlock":       runUnlock,
+	"lock":         runLock,
+	"format":       runFormat,
+	"doctor":       runDoctor,
+	"search":       runSearch,
+	"import":       runImport,
+	"list":         runList,
+	"export":       runExport,
+	"backup":       runBackup,
+	"restore":      runRestore,
+	"maintenance":  runMaintenance,
+	"sync":         runSync,
+	"conflicts":    runConflicts,
+	"merge-driver": runMergeDriver,
+	"history":      runHistory,
+	"undo":         runUndo,
+	"redo":         runRedo,
+	"deleted":      runDeleted,
+	"resurrect":    runResurrect,
+	"verify":       runVerify,
+	"log":          runLog,
+	"gc":           runGC,
+	"plan":         runPlan,
+	"stats":        runStats,
+}
+
+// Global flags, shared by the TUI and every subcommand.
+var (
+	dataDirFlag string
+	profileFlag string
+	keyFileFlag string
+	offlineFlag bool
+)
+
 func main() {
 	var quickAdd string
 	var quickNew bool
 	flag.StringVar(&quickAdd, "n", "", "Quick add a new task")
 	flag.BoolVar(&quickNew, "new", false, "Open input modal for quick task creation")
+	flag.StringVar(&dataDirFlag, "data-dir", "", "Use this data directory instead of the profile's")
+	flag.StringVar(&profileFlag, "profile", "", "Use the named profile from the config file")
+	flag.StringVar(&keyFileFlag, "key-file", "", "Read the encryption key from this file instead of asking for a passphrase")
+	flag.BoolVar(&offlineFlag, "offline", false, "Do not sync with the git remote")
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		run, ok := commands[flag.Arg(0)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown command %q\n", flag.Arg(0))
+			os.Exit(2)
+		}
+		err := run(flag.Args()[1:])
+		flushStores()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if quickAdd != "" {
-		homeDir, _ := os.UserHomeDir()
-		dataDir := fmt.Sprintf("%s/.local/share/invar/tasks", homeDir)
-		store, err := storage.New(dataDir)
+		store, err := openStore()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		t := task.New(quickAdd)
-		if err := store.Save(t); err != nil {
+		err = store.Save(t)
+		flushStores()
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving task: %v\n", err)
 			os.Exit(1)
 		}
@@ -36,15 +99,153 @@ func main() {
 		return
 	}
 
-	m, err := app.New(quickNew)
+	cfg, loc, err := resolveLocation()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := newStore(loc.DataDir)
+	if err == nil {
+		err = unlockStore(store)
+	}
+	if err == nil {
+		err = store.SetSigning(signingConfig(cfg))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	store.SetHooks(hooksConfig(cfg))
+
+	if err := autoBackup(cfg, loc, store); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: automatic backup failed: %v\n", err)
+	}
+	if _, err := store.ApplyRetention(retentionPolicy(cfg), time.Now(), false); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: retention rules failed: %v\n", err)
+	}
+
+	m, err := app.New(cfg, loc, store, quickNew)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	p := tea.NewProgram(*m, tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	// The user may have switched profiles, so sync the store the TUI ended on.
+	if m, ok := final.(app.Model); ok {
+		store = m.Store()
+		opened = append(opened, store)
+	}
+	if autoSyncEnabled(cfg, store) {
+		fmt.Println("Syncing...")
+		_, err := syncStore(context.Background(), store)
+		flushStores()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: sync failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	flushStores()
+}
+
+// opened lists the stores opened with newStore, for flushStores.
+var opened []*storage.Store
+
+// newStore opens a data dir and remembers the store, so main can flush it
+// before exiting.
+func newStore(dir string) (*storage.Store, error) {
+	store, err := storage.New(dir)
+	if err == nil {
+		opened = append(opened, store)
+	}
+	return store, err
+}
+
+// flushStores brings the git index of every store opened up to date, so
+// plain git sees the commits made as a clean worktree.
+func flushStores() {
+	for _, store := range opened {
+		if err := store.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	opened = nil
+}
+
+// resolveLocation loads the config file and applies the global flags and
+// environment to pick the data dir.
+func resolveLocation() (*config.Config, config.Location, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, config.Location{}, err
+	}
+	if offlineFlag || os.Getenv("INVAR_OFFLINE") != "" {
+		cfg.Sync.Offline = true
+	}
+	cfg.Signing.Passphrase = os.Getenv("INVAR_SIGNING_PASSPHRASE")
+	loc, err := cfg.Resolve(dataDirFlag, profileFlag)
+	return cfg, loc, err
+}
+
+// openStore opens the selected data dir, unlocking it if it is encrypted
+// and setting up commit signing and hooks.
+func openStore() (*storage.Store, error) {
+	cfg, loc, err := resolveLocation()
+	if err != nil {
+		return nil, err
+	}
+	store, err := newStore(loc.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := unlockStore(store); err != nil {
+		return nil, err
+	}
+	if err := store.SetSigning(signingConfig(cfg)); err != nil {
+		return nil, err
+	}
+	store.SetHooks(hooksConfig(cfg))
+	return store, nil
+}
+
+// hooksConfig turns the hooks section of the config into the settings the
+// store uses.
+func hooksConfig(cfg *config.Config) storage.Hooks {
+	return storage.Hooks{
+		Dir:     cfg.HooksDir(),
+		Timeout: time.Duration(cfg.Hooks.TimeoutSeconds) * time.Second,
+	}
+}
+
+// findTask returns the task whose ID starts with prefix. The prefix must
+// match exactly one task.
+func findTask(tasks []*task.Task, prefix string) (*task.Task, error) {
+	var found *task.Task
+	for _, t := range tasks {
+		if !strings.HasPrefix(t.ID, prefix) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("task ID %q is ambiguous", prefix)
+		}
+		found = t
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no task matches %q", prefix)
+	}
+	return found, nil
+}
+
+// firstLine returns the first line of s, for one-line listings.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
 }
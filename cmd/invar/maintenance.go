@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/user/invar/internal/config"
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+func runMaintenance(args []string) error {
+	fs := flag.NewFlagSet("maintenance", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "show what would change without changing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar maintenance [-dry-run]")
+	}
+
+	cfg, _, err := resolveLocation()
+	if err != nil {
+		return err
+	}
+	policy := retentionPolicy(cfg)
+	if policy == (storage.Retention{}) {
+		fmt.Printf("No retention rules configured in %s\n", config.Path())
+		return nil
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	report, err := store.ApplyRetention(policy, time.Now(), *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := ""
+	if *dryRun {
+		verb = "would be "
+	}
+	for _, t := range report.Archived {
+		fmt.Printf("%s  %sarchived  %s\n", task.ShortID(t.ID), verb, firstLine(t.Content))
+	}
+	for _, t := range report.Purged {
+		fmt.Printf("%s  %spurged    %s\n", task.ShortID(t.ID), verb, firstLine(t.Content))
+	}
+	for _, t := range report.Emptied {
+		fmt.Printf("%s  %spurged    %s (trash)\n", task.ShortID(t.ID), verb, firstLine(t.Content))
+	}
+	if report.Empty() {
+		fmt.Println("Nothing to do")
+	}
+	return nil
+}
+
+// retentionPolicy turns the configured retention rules into durations.
+func retentionPolicy(cfg *config.Config) storage.Retention {
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+	return storage.Retention{
+		ArchiveCompleted: days(cfg.Retention.ArchiveCompletedAfterDays),
+		PurgeArchived:    days(cfg.Retention.PurgeArchivedAfterDays),
+		EmptyTrash:       days(cfg.Retention.EmptyTrashAfterDays),
+	}
+}
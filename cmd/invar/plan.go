@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+const planUsage = "usage: invar plan [list | new <name> | switch <name>|main | diff [name] | merge [name] | discard <name>]"
+
+func runPlan(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	current, err := store.Plan()
+	if err != nil {
+		return err
+	}
+	// diff and merge default to the plan checked out.
+	name := current
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		if len(args) > 1 {
+			return errors.New(planUsage)
+		}
+		return listPlans(store, current)
+	}
+	if len(args) > 2 {
+		return errors.New(planUsage)
+	}
+	switch args[0] {
+	case "new":
+		if len(args) != 2 {
+			return errors.New(planUsage)
+		}
+		if err := store.CreatePlan(name); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to new plan %s\n", name)
+	case "switch":
+		if len(args) != 2 {
+			return errors.New(planUsage)
+		}
+		main, err := store.MainBranch()
+		if err != nil {
+			return err
+		}
+		if name == "main" || name == main {
+			name = ""
+		}
+		if err := store.SwitchPlan(name); err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Printf("Switched to %s\n", main)
+		} else {
+			fmt.Printf("Switched to plan %s\n", name)
+		}
+	case "diff":
+		if name == "" {
+			return errors.New("not on a plan; name the plan to diff")
+		}
+		diffs, err := store.PlanDiff(name)
+		if err != nil {
+			return err
+		}
+		if len(diffs) == 0 {
+			fmt.Println("No changes")
+		}
+		for _, d := range diffs {
+			fmt.Printf("%s  %s\n", task.ShortID(d.ID), d)
+		}
+	case "merge":
+		if name == "" {
+			return errors.New("not on a plan; name the plan to merge")
+		}
+		conflicts, err := store.MergePlan(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Merged plan %s\n", name)
+		for _, c := range conflicts {
+			fmt.Printf("Conflict: %s  %s\n", task.ShortID(c.TaskID), c.Conflict)
+		}
+		if len(conflicts) > 0 {
+			fmt.Println("Run invar conflicts to resolve them")
+		}
+	case "discard":
+		if len(args) != 2 {
+			return errors.New(planUsage)
+		}
+		if err := store.DiscardPlan(name); err != nil {
+			return err
+		}
+		fmt.Printf("Discarded plan %s\n", name)
+	default:
+		return errors.New(planUsage)
+	}
+	return nil
+}
+
+// listPlans prints the main branch and the plans, marking the one checked
+// out.
+func listPlans(store *storage.Store, current string) error {
+	main, err := store.MainBranch()
+	if err != nil {
+		return err
+	}
+	plans, err := store.Plans()
+	if err != nil {
+		return err
+	}
+	mark := func(on bool) string {
+		if on {
+			return "*"
+		}
+		return " "
+	}
+	fmt.Printf("%s %s (main)\n", mark(current == ""), main)
+	for _, p := range plans {
+		fmt.Printf("%s %s\n", mark(p == current), p)
+	}
+	return nil
+}
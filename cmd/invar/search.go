@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/user/invar/internal/search"
+	"github.com/user/invar/internal/task"
+)
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	history := fs.Bool("history", false, "also search tasks deleted from the repo")
+	limit := fs.Int("limit", 20, "maximum number of results (0 for all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return errors.New("usage: invar search [-history] [-limit N] <query>")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	idx, err := search.Build(store, *history)
+	if err != nil {
+		return err
+	}
+
+	results := idx.Search(query, *limit)
+	if len(results) == 0 {
+		fmt.Println("No matches")
+		return nil
+	}
+	for _, r := range results {
+		fmt.Printf("%s  %-8s  %5.2f  %s\n", task.ShortID(r.Task.ID), r.State, r.Score, firstLine(r.Task.Content))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/invar/internal/stats"
+	"github.com/user/invar/internal/ui"
+)
+
+// statsBarWidth is how many cells the longest bar of a chart takes.
+const statsBarWidth = 24
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	by := fs.String("by", "day", "count per day or per week")
+	since := fs.String("since", "", "start on this day (default 30 days or 12 weeks back)")
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar stats [-by day|week] [-since day] [-json]")
+	}
+
+	var opts stats.Options
+	switch *by {
+	case "day":
+	case "week":
+		opts.Weekly = true
+	default:
+		return fmt.Errorf("invalid -by %q (want day or week)", *by)
+	}
+	if *since != "" {
+		day, err := parseDay(*since)
+		if err != nil {
+			return err
+		}
+		opts.Since = day
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	tasks, err := stats.Collect(store)
+	if err != nil {
+		return err
+	}
+	r := stats.Compute(tasks, opts, time.Now())
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	printStats(r)
+	return nil
+}
+
+func printStats(r stats.Report) {
+	var created, completed, open []int
+	var totalCreated, totalCompleted int
+	for _, p := range r.Periods {
+		created = append(created, p.Created)
+		completed = append(completed, p.Completed)
+		open = append(open, p.Open)
+		totalCreated += p.Created
+		totalCompleted += p.Completed
+	}
+	fmt.Printf("%d %ss from %s\n\n", len(r.Periods), r.By, r.Since.Format("2006-01-02"))
+	fmt.Printf("Created    %s  %d\n", ui.Sparkline(created), totalCreated)
+	fmt.Printf("Completed  %s  %d\n", ui.Sparkline(completed), totalCompleted)
+	fmt.Printf("Open       %s  %d now\n\n", ui.Sparkline(open), open[len(open)-1])
+
+	lt := r.LeadTime
+	if lt.Count == 0 {
+		fmt.Println("Lead time  no tasks completed")
+	} else {
+		fmt.Printf("Lead time  median %s · mean %s · p90 %s over %d tasks\n",
+			stats.FormatDays(lt.MedianDays), stats.FormatDays(lt.MeanDays), stats.FormatDays(lt.P90Days), lt.Count)
+		top := 0
+		for _, b := range lt.Histogram {
+			top = max(top, b.Count)
+		}
+		for _, b := range lt.Histogram {
+			fmt.Printf("  %-9s  %-*s %d\n", b.Label, statsBarWidth, ui.Bar(b.Count, top, statsBarWidth), b.Count)
+		}
+	}
+
+	o := r.Overdue
+	fmt.Printf("\nOverdue    %d of %d deadlines missed", o.Late, o.Due)
+	if o.Due > 0 {
+		fmt.Printf(" (%.0f%%)", o.Rate*100)
+	}
+	fmt.Printf(" · %d overdue now\n\n", o.Open)
+
+	top := 0
+	for _, p := range r.Priorities {
+		top = max(top, p.Open, p.Completed)
+	}
+	fmt.Printf("Priority   %-*s  %s\n", statsBarWidth+4, "open", "completed")
+	for _, p := range r.Priorities {
+		fmt.Printf("  %-7s  %-*s %3d  %-*s %3d\n", p.Priority,
+			statsBarWidth, ui.Bar(p.Open, top, statsBarWidth), p.Open,
+			statsBarWidth, ui.Bar(p.Completed, top, statsBarWidth), p.Completed)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/user/invar/internal/config"
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+// syncTimeout bounds a whole sync, so an unreachable remote cannot hang
+// invar.
+const syncTimeout = 2 * time.Minute
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	remote := fs.String("remote", "", "set the git remote to sync with (a URL or the path of a bare repo)")
+	status := fs.Bool("status", false, "show the remote and the outcome of the last sync")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar sync [-remote url] [-status]")
+	}
+
+	cfg, loc, err := resolveLocation()
+	if err != nil {
+		return err
+	}
+	store, err := newStore(loc.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if *remote != "" {
+		if err := store.SetRemote(*remote); err != nil {
+			return err
+		}
+	}
+	if *status {
+		return printSyncStatus(store)
+	}
+	if cfg.Sync.Offline {
+		return errors.New("offline mode is on")
+	}
+	if err := unlockStore(store); err != nil {
+		return err
+	}
+	if err := store.SetSigning(signingConfig(cfg)); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := syncStore(ctx, store)
+	if errors.Is(err, git.ErrNoRemote) {
+		return errors.New("no remote configured (use invar sync -remote <url>)")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Sync:", result)
+	unresolved := 0
+	for _, c := range result.Conflicts {
+		fmt.Printf("%s  %s\n", task.ShortID(c.TaskID), c)
+		if !c.Resolved {
+			unresolved++
+		}
+	}
+	if unresolved > 0 {
+		fmt.Printf("%d conflicts need a decision: run invar conflicts\n", unresolved)
+	}
+	for _, c := range result.Unverified {
+		fmt.Printf("%s  %-9s  %s\n", c.Hash[:7], c.Status, c.Subject)
+	}
+	if n := len(result.Unverified); n > 0 {
+		fmt.Printf("%d new commits are not signed by a trusted key: run invar verify\n", n)
+	}
+	return nil
+}
+
+func printSyncStatus(store *storage.Store) error {
+	url, err := store.Remote()
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		url = "(none)"
+	}
+	fmt.Println("Remote:      ", url)
+
+	st := store.SyncStatus()
+	if st.LastAttempt.IsZero() {
+		fmt.Println("Last sync:    never")
+		return nil
+	}
+	fmt.Println("Last attempt:", st.LastAttempt.Local().Format("2006-01-02 15:04:05"))
+	if !st.LastSuccess.IsZero() {
+		fmt.Println("Last success:", st.LastSuccess.Local().Format("2006-01-02 15:04:05"))
+	}
+	if st.Error != "" {
+		fmt.Println("Last error:  ", st.Error)
+	}
+	if st.Unverified > 0 {
+		fmt.Println("Unverified:  ", st.Unverified, "commits")
+	}
+	return nil
+}
+
+func syncStore(ctx context.Context, store *storage.Store) (storage.SyncResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+	result, err := store.Sync(ctx)
+	if result.Pulled {
+		if rerr := store.Reload(); err == nil {
+			err = rerr
+		}
+	}
+	return result, err
+}
+
+// autoSyncEnabled reports whether the TUI should sync on start and quit.
+func autoSyncEnabled(cfg *config.Config, store *storage.Store) bool {
+	if !cfg.Sync.Auto || cfg.Sync.Offline {
+		return false
+	}
+	if plan, err := store.Plan(); err != nil || plan != "" {
+		return false
+	}
+	url, err := store.Remote()
+	return err == nil && url != ""
+}
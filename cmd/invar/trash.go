@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+const trashUsage = `usage: invar trash <command>
+
+commands:
+  list                  list trashed tasks
+  restore <id>          move a task back to the active list
+  purge <id>            delete a trashed task for good
+  empty [-days N]       purge tasks trashed more than N days ago (default 30)`
+
+func runTrash(args []string) error {
+	if len(args) == 0 {
+		return errors.New(trashUsage)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		tasks, err := store.ListTrash()
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			deleted := ""
+			if t.DeletedAt != nil {
+				deleted = t.DeletedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%s  %s  %s\n", task.ShortID(t.ID), deleted, firstLine(t.Content))
+		}
+		return nil
+
+	case "restore", "purge":
+		if len(args) != 2 {
+			return errors.New(trashUsage)
+		}
+		tasks, err := store.ListTrash()
+		if err != nil {
+			return err
+		}
+		t, err := findTask(tasks, args[1])
+		if err != nil {
+			return err
+		}
+		if args[0] == "restore" {
+			if err := store.Restore(t.ID); err != nil {
+				return err
+			}
+			fmt.Println("Task restored:", firstLine(t.Content))
+			return nil
+		}
+		if err := store.Purge(t.ID); err != nil {
+			return err
+		}
+		fmt.Println("Task deleted:", firstLine(t.Content))
+		return nil
+
+	case "empty":
+		fs := flag.NewFlagSet("trash empty", flag.ContinueOnError)
+		days := fs.Int("days", 30, "purge tasks trashed more than this many days ago")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		n, err := store.EmptyTrash(time.Now().AddDate(0, 0, -*days))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Purged %d tasks\n", n)
+		return nil
+	}
+
+	return errors.New(trashUsage)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+func runUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	list := fs.Bool("list", false, "show what can be undone and redone")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar undo [-list]")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if *list {
+		undo, redo, err := store.UndoStack()
+		if err != nil {
+			return err
+		}
+		for i := len(redo) - 1; i >= 0; i-- {
+			fmt.Printf("redo  %s  %s\n", redo[i].Commit[:7], redo[i].Subject)
+		}
+		for _, e := range undo {
+			fmt.Printf("undo  %s  %s\n", e.Commit[:7], e.Subject)
+		}
+		return nil
+	}
+
+	subject, err := store.Undo()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Undid", subject)
+	return nil
+}
+
+func runRedo(args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: invar redo")
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	subject, err := store.Redo()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Redid", subject)
+	return nil
+}
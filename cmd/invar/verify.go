@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/user/invar/internal/config"
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/storage"
+)
+
+// signingConfig turns the signing section of the config into the settings
+// the store uses.
+func signingConfig(cfg *config.Config) storage.Signing {
+	return storage.Signing{
+		Format:     cfg.Signing.Format,
+		Key:        cfg.Signing.KeyPath(),
+		Passphrase: cfg.Signing.Passphrase,
+		Trusted:    cfg.Signing.TrustedPaths(),
+	}
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	all := fs.Bool("all", false, "list every commit, not only the ones that fail")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: invar verify [-all]")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	checks, err := store.VerifySignatures()
+	if err != nil {
+		return err
+	}
+
+	counts := map[git.SignatureStatus]int{}
+	for _, c := range checks {
+		counts[c.Status]++
+		if c.Status == git.SignatureGood && !*all {
+			continue
+		}
+		line := fmt.Sprintf("%s  %-9s  %s", c.Hash[:7], c.Status, c.Subject)
+		if c.Signer != "" {
+			line += "  (" + c.Signer + ")"
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d commits: %d good, %d unsigned, %d untrusted, %d bad\n", len(checks),
+		counts[git.SignatureGood], counts[git.SignatureUnsigned], counts[git.SignatureUntrusted], counts[git.SignatureBad])
+	if counts[git.SignatureGood] < len(checks) {
+		return errors.New("some commits are not signed by a trusted key")
+	}
+	return nil
+}
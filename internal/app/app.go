@@ -1,18 +1,22 @@
 package app
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/user/invar/internal/config"
 	"github.com/user/invar/internal/date"
+	"github.com/user/invar/internal/search"
+	"github.com/user/invar/internal/stats"
 	"github.com/user/invar/internal/storage"
 	"github.com/user/invar/internal/task"
 	"github.com/user/invar/internal/ui"
@@ -27,6 +31,15 @@ const (
 	viewArchive
 	viewPriority
 	viewDeadlineMenu
+	viewTrash
+	viewDeleted
+	viewProfileMenu
+	viewSearch
+	viewHistory
+	viewPlanMenu
+	viewPlanName
+	viewPlanDiff
+	viewStats
 )
 
 type inputMode int
@@ -49,9 +62,18 @@ type keyMap struct {
 	Complete key.Binding
 	Archive  key.Binding
 	Delete   key.Binding
+	Restore  key.Binding
 	Priority key.Binding
 	Deadline key.Binding
 	Switch   key.Binding
+	Profile  key.Binding
+	Search   key.Binding
+	Sync     key.Binding
+	History  key.Binding
+	Undo     key.Binding
+	Redo     key.Binding
+	Plans    key.Binding
+	Stats    key.Binding
 	Quit     key.Binding
 }
 
@@ -64,39 +86,78 @@ func defaultKeyMap() keyMap {
 		Complete: key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "complete")),
 		Archive:  key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "archive")),
 		Delete:   key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "delete")),
+		Restore:  key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "restore")),
 		Priority: key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "priority")),
 		Deadline: key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "deadline")),
 		Switch:   key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "switch view")),
+		Profile:  key.NewBinding(key.WithKeys("P"), key.WithHelp("P", "profile")),
+		Search:   key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
+		Sync:     key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "sync")),
+		History:  key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "history")),
+		Undo:     key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "undo")),
+		Redo:     key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "redo")),
+		Plans:    key.NewBinding(key.WithKeys("b"), key.WithHelp("b", "plans")),
+		Stats:    key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "stats")),
 		Quit:     key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
 	}
 }
 
 type Model struct {
-	keys      keyMap
-	store     *storage.Store
-	view      viewState
-	inputMode inputMode
-	textarea  textarea.Model
-	textinput textinput.Model
-	tasks     []*task.Task
-	editTask  *task.Task
+	keys       keyMap
+	cfg        *config.Config
+	loc        config.Location
+	store      *storage.Store
+	view       viewState
+	inputMode  inputMode
+	textarea   textarea.Model
+	textinput  textinput.Model
+	tasks      []*task.Task
+	editTask   *task.Task
 	cursor     int
 	scroll     int
 	menuCursor int
 	width      int
-	height    int
-	quickNew  bool
+	height     int
+	quickNew   bool
+	problems   int
+
+	index         *search.Index
+	indexHistory  bool
+	searchInput   textinput.Model
+	searchHistory bool
+	results       []search.Result
+	searchCursor  int
+
+	versions      []storage.TaskVersion
+	versionCursor int
+	historyReturn viewState
+
+	syncing    bool
+	syncStatus storage.SyncStatus
+	conflicts  int
+
+	notice    string
+	hookError string
+	confirm   *confirmation
+
+	plan       string
+	mainBranch string
+	plans      []string
+	planInput  textinput.Model
+	planDiff   []storage.TaskDiff
+	diffPlan   string
+	diffCursor int
+
+	// deleted caches the Deleted tab, which is read from the whole history:
+	// it is loaded when the tab is opened, not on every reload.
+	deleted []*task.Task
+
+	stats       stats.Report
+	statTasks   []*task.Task
+	statsWeekly bool
 }
 
-func New(quickNew bool) (*Model, error) {
-	homeDir, _ := os.UserHomeDir()
-	dataDir := filepath.Join(homeDir, ".local", "share", "invar", "tasks")
-
-	store, err := storage.New(dataDir)
-	if err != nil {
-		return nil, err
-	}
-
+func New(cfg *config.Config, loc config.Location, store *storage.Store, quickNew bool) (*Model, error) {
 	ta := textarea.New()
 	ta.SetHeight(5)
 	ta.FocusedStyle.Base = ta.FocusedStyle.Base.
@@ -105,19 +166,25 @@ func New(quickNew bool) (*Model, error) {
 		BorderForeground(ui.ColorBorder)
 
 	ti := textinput.New()
-	ti.Placeholder = "today, tomorrow, next week, or YYYY-MM-DD"
+	ti.Placeholder = "next friday, 3pm tomorrow, +5d, or YYYY-MM-DD"
+	ti.Width = 52
 	ti.PromptStyle = lipgloss.NewStyle().Foreground(ui.ColorPrimary)
 	ti.TextStyle = lipgloss.NewStyle().Foreground(ui.ColorFg)
 	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(ui.ColorMuted)
 
 	m := &Model{
 		keys:      defaultKeyMap(),
+		cfg:       cfg,
+		loc:       loc,
 		store:     store,
 		view:      viewList,
 		inputMode: modeNew,
 		textarea:  ta,
 		textinput: ti,
 		quickNew:  quickNew,
+
+		searchInput: newSearchInput(),
+		planInput:   newPlanInput(),
 	}
 
 	if quickNew {
@@ -125,11 +192,46 @@ func New(quickNew bool) (*Model, error) {
 		m.textarea.Focus()
 	}
 
+	m.plan, _ = store.Plan()
+	m.mainBranch, _ = store.MainBranch()
 	m.loadTasks()
+	m.syncStatus = store.SyncStatus()
+	m.countConflicts()
 	return m, nil
 }
 
+// integrityMsg carries the number of problems the doctor found in the
+// data dir of store.
+type integrityMsg struct {
+	store    *storage.Store
+	problems int
+}
+
+// checkIntegrity runs the doctor's checks in the background, on a store of
+// its own so changes can go on meanwhile, so the dashboard can warn about
+// the problems found.
+func (m Model) checkIntegrity() tea.Cmd {
+	store := m.store
+	check, err := store.Reopen()
+	if err != nil {
+		return nil
+	}
+	return func() tea.Msg {
+		problems, _ := check.Check()
+		return integrityMsg{store: store, problems: len(problems)}
+	}
+}
+
 func (m *Model) loadTasks() {
+	if m.view == viewTrash {
+		m.loadTrash()
+		return
+	}
+	if m.view == viewDeleted {
+		m.loadDeleted()
+		return
+	}
+
 	tasks, _ := m.store.List(m.view == viewArchive)
 
 	sort.Slice(tasks, func(i, j int) bool {
@@ -163,8 +265,25 @@ func (m *Model) loadTasks() {
 	})
 
 	m.tasks = tasks
+	m.clampCursor()
+}
+
+// loadTrash loads trashed tasks, most recently deleted first.
+func (m *Model) loadTrash() {
+	tasks, _ := m.store.ListTrash()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].DeletedAt == nil || tasks[j].DeletedAt == nil {
+			return tasks[i].DeletedAt != nil
+		}
+		return tasks[i].DeletedAt.After(*tasks[j].DeletedAt)
+	})
 
-	// Clamp cursor.
+	m.tasks = tasks
+	m.clampCursor()
+}
+
+func (m *Model) clampCursor() {
 	if m.cursor >= len(m.tasks) {
 		m.cursor = len(m.tasks) - 1
 	}
@@ -173,6 +292,31 @@ func (m *Model) loadTasks() {
 	}
 }
 
+// save writes a task and reports the outcome; see report.
+func (m *Model) save(t *task.Task) bool {
+	return m.report(m.store.Save(t))
+}
+
+// report shows what went wrong with a change: a hook refusing it as a
+// notice, and a hook failing as a warning that stays until a change goes
+// through. It reports whether the change was made.
+func (m *Model) report(err error) bool {
+	var veto *storage.HookVeto
+	var hookErr *storage.HookError
+	m.hookError = ""
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &veto):
+		m.notice = veto.Hook + " refused: " + firstLine(veto.Message)
+	case errors.As(err, &hookErr):
+		m.hookError = firstLine(hookErr.Error())
+	default:
+		m.notice = "failed: " + firstLine(err.Error())
+	}
+	return false
+}
+
 func (m *Model) selectedTask() *task.Task {
 	if len(m.tasks) == 0 || m.cursor >= len(m.tasks) {
 		return nil
@@ -181,10 +325,15 @@ func (m *Model) selectedTask() *task.Task {
 }
 
 func (m Model) Init() tea.Cmd {
+	var cmds []tea.Cmd
 	if m.quickNew {
-		return textarea.Blink
+		cmds = append(cmds, textarea.Blink)
+	}
+	if m.cfg.Sync.Auto {
+		cmds = append(cmds, func() tea.Msg { return autoSyncMsg{} })
 	}
-	return nil
+	cmds = append(cmds, m.checkIntegrity())
+	return tea.Batch(cmds...)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -194,7 +343,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		m.textarea.SetWidth(56)
 
+	case autoSyncMsg:
+		return m.startSync()
+
+	case syncDoneMsg:
+		return m.handleSyncDone(msg)
+
+	case integrityMsg:
+		if msg.store == m.store {
+			m.problems = msg.problems
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		m.notice = ""
+		if m.syncing && m.writes(msg) {
+			return m, nil
+		}
+		if m.confirm != nil {
+			return m.handleConfirmKey(msg)
+		}
 		switch m.view {
 		case viewInput:
 			return m.handleInputKey(msg)
@@ -204,6 +372,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handlePriorityKey(msg)
 		case viewDeadlineMenu:
 			return m.handleDeadlineMenuKey(msg)
+		case viewProfileMenu:
+			return m.handleProfileMenuKey(msg)
+		case viewSearch:
+			return m.handleSearchKey(msg)
+		case viewHistory:
+			return m.handleHistoryKey(msg)
+		case viewPlanMenu:
+			return m.handlePlanMenuKey(msg)
+		case viewPlanName:
+			return m.handlePlanNameKey(msg)
+		case viewPlanDiff:
+			return m.handlePlanDiffKey(msg)
+		case viewStats:
+			return m.handleStatsKey(msg)
+		case viewTrash:
+			if model, cmd, ok := m.handleTrashKey(msg); ok {
+				return model, cmd
+			}
+		case viewDeleted:
+			if model, cmd, ok := m.handleDeletedKey(msg); ok {
+				return model, cmd
+			}
 		}
 
 		switch {
@@ -232,15 +422,43 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.textarea.Focus()
 			return m, textarea.Blink
 		case key.Matches(msg, m.keys.Switch):
-			if m.view == viewList {
+			switch m.view {
+			case viewList:
 				m.view = viewArchive
-			} else {
+			case viewArchive:
+				m.view = viewTrash
+			case viewTrash:
+				m.view = viewDeleted
+				m.deleted = nil
+			default:
 				m.view = viewList
 			}
 			m.cursor = 0
 			m.scroll = 0
 			m.loadTasks()
 			return m, nil
+		case key.Matches(msg, m.keys.Search):
+			return m.openSearch()
+		case key.Matches(msg, m.keys.Sync):
+			return m.startSync()
+		case key.Matches(msg, m.keys.History):
+			return m.openHistory()
+		case key.Matches(msg, m.keys.Undo):
+			return m.undo()
+		case key.Matches(msg, m.keys.Redo):
+			return m.redo()
+		case key.Matches(msg, m.keys.Plans):
+			return m.openPlans()
+		case key.Matches(msg, m.keys.Stats):
+			return m.openStats()
+		case key.Matches(msg, m.keys.Profile):
+			m.view = viewProfileMenu
+			m.menuCursor = 0
+			for i, name := range m.cfg.ProfileNames() {
+				if name == m.loc.Profile {
+					m.menuCursor = i
+				}
+			}
 		case key.Matches(msg, m.keys.Complete):
 			if t := m.selectedTask(); t != nil {
 				if t.CompletedAt != nil {
@@ -248,7 +466,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					t.Complete()
 				}
-				m.store.Save(t)
+				m.save(t)
 				m.loadTasks()
 			}
 		case key.Matches(msg, m.keys.Archive):
@@ -258,12 +476,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					t.Archive()
 				}
-				m.store.Save(t)
+				m.save(t)
 				m.loadTasks()
 			}
 		case key.Matches(msg, m.keys.Delete):
 			if t := m.selectedTask(); t != nil {
-				m.store.Delete(t.ID)
+				m.report(m.store.Delete(t.ID))
 				m.loadTasks()
 			}
 		case key.Matches(msg, m.keys.Priority):
@@ -312,14 +530,18 @@ func (m Model) handleInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter":
 		content := m.textarea.Value()
 		if content != "" {
+			var ok bool
 			if m.inputMode == modeEdit && m.editTask != nil {
 				m.editTask.Content = content
-				m.store.Save(m.editTask)
+				ok = m.save(m.editTask)
 			} else {
-				t := task.New(content)
-				m.store.Save(t)
+				ok = m.save(task.New(content))
 			}
 			m.loadTasks()
+			// Keep the text so it can be fixed after a hook refused it.
+			if !ok {
+				return m, nil
+			}
 		}
 		if m.quickNew {
 			return m, tea.Quit
@@ -337,6 +559,33 @@ func (m Model) handleInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// handleTrashKey handles the keys that behave differently in the trash view.
+// Navigation and view switching fall through to the regular handler; editing
+// keys are swallowed since trashed tasks are read-only.
+func (m Model) handleTrashKey(msg tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	switch {
+	case key.Matches(msg, m.keys.Restore):
+		if t := m.selectedTask(); t != nil {
+			m.report(m.store.Restore(t.ID))
+			m.loadTasks()
+		}
+	case key.Matches(msg, m.keys.Delete):
+		if t := m.selectedTask(); t != nil {
+			id := t.ID
+			m.ask(fmt.Sprintf("Delete %q forever?", shorten(firstLine(t.Content), 30)), func(m *Model) {
+				m.report(m.store.Purge(id))
+				m.loadTasks()
+			})
+		}
+	case key.Matches(msg, m.keys.New), key.Matches(msg, m.keys.Edit),
+		key.Matches(msg, m.keys.Complete), key.Matches(msg, m.keys.Archive),
+		key.Matches(msg, m.keys.Priority), key.Matches(msg, m.keys.Deadline):
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
 func (m Model) handleDeadlineKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc":
@@ -345,10 +594,13 @@ func (m Model) handleDeadlineKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case "enter":
 		if m.editTask != nil {
-			input := m.textinput.Value()
-			deadline, _ := date.Parse(input)
+			deadline, err := date.Parse(m.textinput.Value())
+			if err != nil {
+				// The preview already says what is wrong.
+				return m, nil
+			}
 			m.editTask.SetDeadline(deadline)
-			m.store.Save(m.editTask)
+			m.save(m.editTask)
 			m.loadTasks()
 		}
 		m.view = viewList
@@ -379,7 +631,7 @@ func (m Model) handlePriorityKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.editTask != nil {
 			priorities := []task.Priority{task.PriorityHigh, task.PriorityMedium, task.PriorityLow}
 			m.editTask.SetPriority(priorities[m.menuCursor])
-			m.store.Save(m.editTask)
+			m.save(m.editTask)
 			m.loadTasks()
 		}
 		m.view = viewList
@@ -420,21 +672,21 @@ func (m Model) handleDeadlineMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case 0: // Today
 			d, _ := date.Parse("today")
 			m.editTask.SetDeadline(d)
-			m.store.Save(m.editTask)
+			m.save(m.editTask)
 			m.loadTasks()
 			m.view = viewList
 			m.editTask = nil
 		case 1: // Tomorrow
 			d, _ := date.Parse("tomorrow")
 			m.editTask.SetDeadline(d)
-			m.store.Save(m.editTask)
+			m.save(m.editTask)
 			m.loadTasks()
 			m.view = viewList
 			m.editTask = nil
 		case 2: // Next week
 			d, _ := date.Parse("next week")
 			m.editTask.SetDeadline(d)
-			m.store.Save(m.editTask)
+			m.save(m.editTask)
 			m.loadTasks()
 			m.view = viewList
 			m.editTask = nil
@@ -445,7 +697,7 @@ func (m Model) handleDeadlineMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, textinput.Blink
 		case 4: // Clear deadline
 			m.editTask.SetDeadline(nil)
-			m.store.Save(m.editTask)
+			m.save(m.editTask)
 			m.loadTasks()
 			m.view = viewList
 			m.editTask = nil
@@ -455,6 +707,74 @@ func (m Model) handleDeadlineMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m Model) handleProfileMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	names := m.cfg.ProfileNames()
+	switch msg.String() {
+	case "esc":
+		m.view = viewList
+		return m, nil
+	case "up", "k":
+		if m.menuCursor > 0 {
+			m.menuCursor--
+		}
+	case "down", "j":
+		if m.menuCursor < len(names)-1 {
+			m.menuCursor++
+		}
+	case "enter":
+		cmd := m.switchProfile(names[m.menuCursor])
+		m.view = viewList
+		m.cursor = 0
+		m.scroll = 0
+		m.loadTasks()
+		return m, cmd
+	}
+	return m, nil
+}
+
+// switchProfile points the model at another profile's data dir. The
+// current store is kept, with a notice saying why, if the new one cannot be
+// opened, including when it is encrypted and its key is not in the session
+// cache. It returns the command that checks the new data dir.
+func (m *Model) switchProfile(name string) tea.Cmd {
+	loc, err := m.cfg.Location(name)
+	if err != nil {
+		m.notice = "switch failed: " + firstLine(err.Error())
+		return nil
+	}
+	store, err := storage.New(loc.DataDir)
+	if err != nil {
+		m.notice = "switch failed: " + firstLine(err.Error())
+		return nil
+	}
+	if store.Locked() {
+		m.notice = fmt.Sprintf("profile %s is locked · run invar -profile %s unlock", name, name)
+		return nil
+	}
+	if err := store.SetSigning(m.store.Signing()); err != nil {
+		m.notice = "switch failed: " + firstLine(err.Error())
+		return nil
+	}
+	store.SetHooks(m.store.Hooks())
+	// Only the store the TUI ends on is flushed on exit. One still syncing
+	// is flushed the next time it is opened instead.
+	if !m.syncing {
+		if err := m.store.Flush(); err != nil {
+			m.notice = "flush failed: " + firstLine(err.Error())
+		}
+	}
+	m.loc = loc
+	m.store = store
+	m.index = nil
+	m.deleted = nil
+	m.plan, _ = store.Plan()
+	m.mainBranch, _ = store.MainBranch()
+	m.syncStatus = store.SyncStatus()
+	m.countConflicts()
+	m.problems = 0
+	return m.checkIntegrity()
+}
+
 func (m Model) View() string {
 	switch m.view {
 	case viewInput:
@@ -469,6 +789,20 @@ func (m Model) View() string {
 		})
 	case viewDeadlineMenu:
 		return m.viewDeadlineMenuOverlay()
+	case viewProfileMenu:
+		return m.viewOptionsOverlay("Profile", m.cfg.ProfileNames())
+	case viewSearch:
+		return m.viewSearchOverlay()
+	case viewHistory:
+		return m.viewHistoryOverlay()
+	case viewPlanMenu:
+		return m.viewPlanMenuOverlay()
+	case viewPlanName:
+		return m.viewPlanNameOverlay()
+	case viewPlanDiff:
+		return m.viewPlanDiffOverlay()
+	case viewStats:
+		return m.viewStatsOverlay()
 	}
 	return m.viewDashboard()
 }
@@ -485,17 +819,18 @@ func (m Model) viewDashboard() string {
 		Foreground(ui.ColorPrimary).
 		Bold(true).
 		Render("◆ invar")
+	if m.loc.Profile != "" {
+		appName += lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(" · " + m.loc.Profile)
+	}
+	appName += m.planLabel()
 
-	activeLabel := "Active"
-	archiveLabel := "Archive"
-	if m.view == viewArchive {
-		activeLabel = ui.TabInactive.Render(activeLabel)
-		archiveLabel = ui.TabActive.Render(archiveLabel)
-	} else {
-		activeLabel = ui.TabActive.Render(activeLabel)
-		archiveLabel = ui.TabInactive.Render(archiveLabel)
+	tab := func(label string, v viewState) string {
+		if m.view == v {
+			return ui.TabActive.Render(label)
+		}
+		return ui.TabInactive.Render(label)
 	}
-	tabs := activeLabel + " " + archiveLabel
+	tabs := tab("Active", viewList) + " " + tab("Archive", viewArchive) + " " + tab("Trash", viewTrash) + " " + tab("Deleted", viewDeleted)
 
 	headerLeft := lipgloss.NewStyle().Padding(0, 2).Render(appName)
 	headerRight := lipgloss.NewStyle().Padding(0, 2).Render(tabs)
@@ -508,6 +843,12 @@ func (m Model) viewDashboard() string {
 		Width(inner).
 		Render(headerLeft + strings.Repeat(" ", gap) + headerRight)
 
+	// Warning banners.
+	var banners []string
+	for _, text := range m.warnings() {
+		banners = append(banners, ui.WarningBanner.Width(inner).Render(text))
+	}
+
 	// Task rows.
 	vis := m.visibleRowCount()
 	var rows []string
@@ -527,18 +868,30 @@ func (m Model) viewDashboard() string {
 	// Footer.
 	total, pending, overdue := m.taskCounts()
 	statsText := fmt.Sprintf("%d tasks · %d pending · %d overdue", total, pending, overdue)
+	if label := m.syncLabel(); label != "" {
+		statsText += " · " + label
+	}
+	if m.notice != "" {
+		statsText += " · " + m.notice
+	}
 	stats := ui.FooterStats.Width(inner).Render(statsText)
 
-	helpText := "n new  e edit  space complete  p priority  d deadline  a archive  D delete  tab switch  q quit"
+	helpText := "n new  e edit  space complete  p priority  d deadline  a archive  D delete  h history  u undo  / search  tab switch  b plans  s stats  P profile  S sync  q quit"
+	switch m.view {
+	case viewTrash:
+		helpText = "r restore  D delete forever  h history  u undo  / search  tab switch  q quit"
+	case viewDeleted:
+		helpText = "r resurrect  h history  u undo  / search  tab switch  q quit"
+	}
 	helpLine := ui.FooterHelp.Width(inner).Render(helpText)
+	if m.confirm != nil {
+		helpLine = ui.FooterHelp.Width(inner).Render(m.confirmPrompt())
+	}
 
 	// Assemble the card.
-	body := lipgloss.JoinVertical(lipgloss.Left,
-		header,
-		taskArea,
-		stats,
-		helpLine,
-	)
+	sections := append([]string{header}, banners...)
+	sections = append(sections, taskArea, stats, helpLine)
+	body := lipgloss.JoinVertical(lipgloss.Left, sections...)
 
 	card := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
@@ -564,12 +917,15 @@ func (m Model) viewOverlay(mode string) string {
 		}
 		hint = "Enter to save · Shift+Enter for new line · Esc to cancel"
 		content = m.textarea.View()
+		if problem := m.notice + m.hookError; problem != "" {
+			content += "\n" + lipgloss.NewStyle().Foreground(ui.ColorHigh).Render(problem)
+		}
 	} else {
 		title = "Set Deadline"
-		hint = "Enter to save · Esc to cancel"
+		hint = "Enter to save · empty or none clears · Esc to cancel"
 		content = lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(
-			"Examples: today, tomorrow, next week, 2026-02-01",
-		) + "\n\n" + m.textinput.View()
+			"Examples: fri 3pm, +5d, eom, march 3rd, 2026-02-01",
+		) + "\n\n" + m.textinput.View() + "\n\n" + m.deadlinePreview()
 	}
 
 	titleRendered := ui.OverlayTitle.Render(title)
@@ -591,6 +947,34 @@ func (m Model) viewOverlay(mode string) string {
 	)
 }
 
+// deadlinePreview shows what the deadline input resolves to, or why it
+// does not parse.
+func (m Model) deadlinePreview() string {
+	d, err := date.Parse(m.textinput.Value())
+	var perr *date.ParseError
+	switch {
+	case errors.As(err, &perr):
+		reason := perr.Reason
+		if reason == "" {
+			reason = "not a date"
+		}
+		lines := []string{"✗ " + reason}
+		if len(perr.Suggestions) > 0 {
+			lines = append(lines, "  did you mean "+strings.Join(perr.Suggestions, " or ")+"?")
+		}
+		return lipgloss.NewStyle().Foreground(ui.ColorHigh).Render(strings.Join(lines, "\n"))
+	case err != nil:
+		return lipgloss.NewStyle().Foreground(ui.ColorHigh).Render("✗ " + err.Error())
+	case d == nil:
+		return lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("→ no deadline")
+	}
+	layout := "Mon Jan 2, 15:04"
+	if d.Year() != time.Now().Year() {
+		layout = "Mon Jan 2 2006, 15:04"
+	}
+	return lipgloss.NewStyle().Foreground(ui.ColorPrimary).Render("→ " + d.Format(layout))
+}
+
 func (m Model) viewMenuOverlay(title string, items []menuItem) string {
 	titleRendered := ui.OverlayTitle.Render(title)
 	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("↑/↓ navigate · Enter select · Esc cancel")
@@ -625,13 +1009,18 @@ func (m Model) viewMenuOverlay(title string, items []menuItem) string {
 }
 
 func (m Model) viewDeadlineMenuOverlay() string {
-	titleRendered := ui.OverlayTitle.Render("Deadline")
-	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("↑/↓ navigate · Enter select · Esc cancel")
-
 	options := []string{"Today", "Tomorrow", "Next week", "Custom..."}
 	if m.editTask != nil && m.editTask.Deadline != nil {
 		options = append(options, "Clear deadline")
 	}
+	return m.viewOptionsOverlay("Deadline", options)
+}
+
+// viewOptionsOverlay renders a menu of plain text options with the cursor
+// on m.menuCursor.
+func (m Model) viewOptionsOverlay(title string, options []string) string {
+	titleRendered := ui.OverlayTitle.Render(title)
+	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("↑/↓ navigate · Enter select · Esc cancel")
 
 	optStyle := lipgloss.NewStyle().Foreground(ui.ColorFg)
 	var rows []string
@@ -661,10 +1050,52 @@ func (m Model) viewDeadlineMenuOverlay() string {
 	)
 }
 
+// writes reports whether a key could change tasks, which has to wait
+// while a sync is running.
+func (m Model) writes(msg tea.KeyMsg) bool {
+	if m.confirm != nil {
+		return msg.String() == "y"
+	}
+	switch m.view {
+	case viewList, viewArchive, viewTrash, viewDeleted:
+		return !key.Matches(msg, m.keys.Up, m.keys.Down, m.keys.Switch, m.keys.Search, m.keys.History, m.keys.Stats, m.keys.Quit)
+	case viewSearch, viewProfileMenu, viewStats:
+		return false
+	case viewPlanDiff:
+		return msg.String() == "m"
+	case viewPlanMenu:
+		return slices.Contains([]string{"enter", "n", "m", "D"}, msg.String())
+	}
+	return msg.String() == "enter"
+}
+
+// warnings returns the banner lines shown above the task list.
+func (m Model) warnings() []string {
+	var lines []string
+	if m.problems > 0 {
+		lines = append(lines, fmt.Sprintf("⚠ %d problems in the data dir · run invar doctor", m.problems))
+	}
+	if m.syncStatus.Error != "" && !m.syncing && !m.cfg.Sync.Offline {
+		lines = append(lines, "⚠ sync failed: "+firstLine(m.syncStatus.Error))
+	}
+	if m.conflicts > 0 {
+		lines = append(lines, fmt.Sprintf("⚠ %d sync conflicts · run invar conflicts", m.conflicts))
+	}
+	if m.hookError != "" {
+		lines = append(lines, "⚠ "+m.hookError)
+	}
+	if n := m.syncStatus.Unverified; n > 0 {
+		lines = append(lines, fmt.Sprintf("⚠ %d synced commits are unsigned or untrusted · run invar verify", n))
+	}
+	return lines
+}
+
 // visibleRowCount returns how many task rows fit in the viewport.
-// Each task is 3 lines. Chrome = header(1) + stats(1) + help(1) + border(2) = 5.
+// Each task is 3 lines. Chrome = header(1) + stats(1) + help(1) + border(2) = 5,
+// plus one line for each warning banner.
 func (m Model) visibleRowCount() int {
-	available := max(m.height-2-5, 4)
+	chrome := 5 + len(m.warnings())
+	available := max(m.height-2-chrome, 4)
 	return available / 4
 }
 
@@ -709,8 +1140,10 @@ func (m Model) renderTaskRow(t *task.Task, selected bool, width int) string {
 	}
 
 	deadline := ""
-	if t.Deadline != nil {
-		dl := t.Deadline.Format("Jan 02")
+	if t.DeletedAt != nil {
+		deadline = ui.DeadlineNormal.Render("deleted " + t.DeletedAt.Format("Jan 02"))
+	} else if t.Deadline != nil {
+		dl := task.FormatDeadline(*t.Deadline, "Jan 02")
 		if t.IsOverdue() {
 			deadline = ui.DeadlineOverdue.Render("! " + dl)
 		} else {
@@ -0,0 +1,35 @@
+package app
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/user/invar/internal/ui"
+)
+
+// confirmation is an action that cannot be undone, waiting for the user to
+// press y.
+type confirmation struct {
+	prompt string
+	run    func(m *Model)
+}
+
+// ask holds run back until the user answers prompt with y.
+func (m *Model) ask(prompt string, run func(m *Model)) {
+	m.confirm = &confirmation{prompt: prompt, run: run}
+}
+
+// handleConfirmKey runs the pending action on y and drops it on any other
+// key.
+func (m Model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.confirm
+	m.confirm = nil
+	if msg.String() == "y" {
+		c.run(&m)
+	}
+	return m, nil
+}
+
+// confirmPrompt renders the pending question in place of a hint line.
+func (m Model) confirmPrompt() string {
+	return lipgloss.NewStyle().Foreground(ui.ColorHigh).Render(m.confirm.prompt + " y to confirm · any other key cancels")
+}
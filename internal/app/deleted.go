@@ -0,0 +1,48 @@
+package app
+
+import (
+	"slices"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/user/invar/internal/task"
+)
+
+// loadDeleted shows the tasks deleted from the repo, recovered from the
+// git history, most recently deleted first. Each row shows when its task
+// was deleted. The history is only read when m.deleted was reset.
+func (m *Model) loadDeleted() {
+	if m.deleted == nil {
+		deleted, _ := m.store.Deleted()
+		m.deleted = []*task.Task{}
+		for _, d := range deleted {
+			t := *d.Task
+			deletedAt := d.DeletedAt
+			t.DeletedAt = &deletedAt
+			m.deleted = append(m.deleted, &t)
+		}
+	}
+	m.tasks = m.deleted
+	m.clampCursor()
+}
+
+func (m Model) handleDeletedKey(msg tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	switch {
+	case key.Matches(msg, m.keys.Restore):
+		if t := m.selectedTask(); t != nil {
+			if _, err := m.store.Resurrect(t.ID); !m.report(err) {
+				return m, nil, true
+			}
+			m.deleted = slices.DeleteFunc(slices.Clone(m.deleted), func(d *task.Task) bool { return d.ID == t.ID })
+			m.loadTasks()
+			m.notice = "resurrected " + firstLine(t.Content)
+		}
+	case key.Matches(msg, m.keys.New), key.Matches(msg, m.keys.Edit),
+		key.Matches(msg, m.keys.Complete), key.Matches(msg, m.keys.Archive),
+		key.Matches(msg, m.keys.Priority), key.Matches(msg, m.keys.Deadline),
+		key.Matches(msg, m.keys.Delete):
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
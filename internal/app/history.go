@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/user/invar/internal/task"
+	"github.com/user/invar/internal/ui"
+)
+
+// maxHistoryRows is how many versions the history pane shows at once.
+const maxHistoryRows = 10
+
+// openHistory shows the committed versions of the selected task.
+func (m Model) openHistory() (tea.Model, tea.Cmd) {
+	t := m.selectedTask()
+	if t == nil {
+		return m, nil
+	}
+	versions, err := m.store.History(t.ID)
+	if err != nil || len(versions) == 0 {
+		return m, nil
+	}
+	m.versions = versions
+	m.versionCursor = 0
+	m.historyReturn = m.view
+	m.view = viewHistory
+	return m, nil
+}
+
+func (m Model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "h":
+		m.view = m.historyReturn
+	case "up", "k":
+		if m.versionCursor > 0 {
+			m.versionCursor--
+		}
+	case "down", "j":
+		if m.versionCursor < len(m.versions)-1 {
+			m.versionCursor++
+		}
+	case "enter", "r":
+		v := m.versions[m.versionCursor]
+		if v.Task == nil || !m.report(m.store.RestoreVersion(v)) {
+			return m, nil
+		}
+		m.view = viewList
+		if v.Task.Archived {
+			m.view = viewArchive
+		}
+		m.loadTasks()
+		m.selectTask(v.Task.ID)
+	}
+	return m, nil
+}
+
+func (m Model) viewHistoryOverlay() string {
+	titleRendered := ui.OverlayTitle.Render("History")
+	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(
+		"↑/↓ navigate · Enter restore this version · Esc close")
+	muted := lipgloss.NewStyle().Foreground(ui.ColorMuted)
+	selected := lipgloss.NewStyle().Foreground(ui.ColorPrimary).Bold(true)
+
+	start := max(m.versionCursor-maxHistoryRows+1, 0)
+	end := min(start+maxHistoryRows, len(m.versions))
+	var rows []string
+	for i := start; i < end; i++ {
+		v := m.versions[i]
+		when := muted.Render(v.When.Local().Format("Jan 02 15:04"))
+		subject := shorten(v.Subject, 56)
+		if i == m.versionCursor {
+			rows = append(rows, selected.Render("▸ ")+when+" "+selected.Render(subject))
+		} else {
+			rows = append(rows, "  "+when+" "+lipgloss.NewStyle().Foreground(ui.ColorFg).Render(subject))
+		}
+	}
+
+	// Preview of the selected version.
+	var preview []string
+	v := m.versions[m.versionCursor]
+	if v.Task == nil {
+		preview = append(preview, muted.Render("Deleted"))
+	} else {
+		t := v.Task
+		preview = append(preview, lipgloss.NewStyle().Foreground(ui.ColorFg).Render(firstLine(t.Content)))
+		details := []string{string(t.Priority)}
+		if t.Deadline != nil {
+			details = append(details, "due "+task.FormatDeadline(*t.Deadline, "Jan 02"))
+		}
+		if len(t.Tags) > 0 {
+			details = append(details, strings.Join(t.Tags, ", "))
+		}
+		if t.CompletedAt != nil {
+			details = append(details, "done")
+		}
+		if t.Archived {
+			details = append(details, "archived")
+		}
+		if v.Trashed {
+			details = append(details, "in trash")
+		}
+		preview = append(preview, muted.Render(strings.Join(details, " · ")))
+	}
+	if len(v.Changes) > 0 {
+		var changed []string
+		for _, c := range v.Changes {
+			changed = append(changed, c.Field)
+		}
+		preview = append(preview, muted.Render(fmt.Sprintf("changed: %s", strings.Join(changed, ", "))))
+	}
+
+	card := ui.OverlayCard.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			titleRendered,
+			"",
+			strings.Join(rows, "\n"),
+			"",
+			strings.Join(preview, "\n"),
+			"",
+			hintRendered,
+		),
+	)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		card,
+	)
+}
@@ -0,0 +1,312 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/ui"
+)
+
+// maxDiffRows is how many changed tasks the plan diff pane shows at once.
+const maxDiffRows = 12
+
+func newPlanInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "plan name, e.g. reprioritize"
+	ti.PromptStyle = lipgloss.NewStyle().Foreground(ui.ColorPrimary)
+	ti.TextStyle = lipgloss.NewStyle().Foreground(ui.ColorFg)
+	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(ui.ColorMuted)
+	return ti
+}
+
+// checkPlan notices when another branch has been checked out, by a plan
+// operation or by another invar process before a sync, and reloads the
+// tasks. Reading the branch takes a few file reads, so it is only called
+// after those operations rather than on every key.
+func (m *Model) checkPlan() {
+	plan, err := m.store.Plan()
+	if err != nil {
+		return
+	}
+	main, err := m.store.MainBranch()
+	if err != nil {
+		return
+	}
+	if plan == m.plan && main == m.mainBranch {
+		return
+	}
+	m.plan, m.mainBranch = plan, main
+	m.index = nil
+	m.cursor = 0
+	m.scroll = 0
+	m.loadTasks()
+}
+
+// openPlans shows the branch menu: the main branch, the plans and an
+// entry to start a new plan.
+func (m Model) openPlans() (tea.Model, tea.Cmd) {
+	plans, err := m.store.Plans()
+	if err != nil {
+		m.notice = "plans failed: " + firstLine(err.Error())
+		return m, nil
+	}
+	m.plans = plans
+	m.menuCursor = 0
+	for i, p := range plans {
+		if p == m.plan {
+			m.menuCursor = i + 1
+		}
+	}
+	m.view = viewPlanMenu
+	return m, nil
+}
+
+// selectedPlan returns the plan under the menu cursor, or "" for the main
+// branch and the new plan entry.
+func (m Model) selectedPlan() string {
+	if m.menuCursor == 0 || m.menuCursor > len(m.plans) {
+		return ""
+	}
+	return m.plans[m.menuCursor-1]
+}
+
+func (m Model) handlePlanMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	plan := m.selectedPlan()
+	switch msg.String() {
+	case "esc", "b":
+		m.view = viewList
+	case "up", "k":
+		if m.menuCursor > 0 {
+			m.menuCursor--
+		}
+	case "down", "j":
+		if m.menuCursor < len(m.plans)+1 {
+			m.menuCursor++
+		}
+	case "n":
+		return m.newPlan()
+	case "enter":
+		if m.menuCursor == len(m.plans)+1 {
+			return m.newPlan()
+		}
+		m.view = viewList
+		if m.report(m.store.SwitchPlan(plan)) {
+			m.checkPlan()
+		}
+	case "d":
+		if plan == "" {
+			return m, nil
+		}
+		diff, err := m.store.PlanDiff(plan)
+		if err != nil {
+			m.view = viewList
+			m.report(err)
+			return m, nil
+		}
+		m.planDiff = diff
+		m.diffPlan = plan
+		m.diffCursor = 0
+		m.view = viewPlanDiff
+	case "m":
+		if plan != "" {
+			return m.mergePlan(plan)
+		}
+	case "D":
+		if plan == "" {
+			return m, nil
+		}
+		m.ask(fmt.Sprintf("Discard plan %s?", plan), func(m *Model) {
+			m.view = viewList
+			if m.report(m.store.DiscardPlan(plan)) {
+				m.notice = "discarded plan " + plan
+				m.checkPlan()
+			}
+		})
+	}
+	return m, nil
+}
+
+// newPlan asks for the name of a plan to start.
+func (m Model) newPlan() (tea.Model, tea.Cmd) {
+	m.view = viewPlanName
+	m.planInput.SetValue("")
+	m.planInput.Focus()
+	return m, textinput.Blink
+}
+
+func (m Model) handlePlanNameKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.view = viewPlanMenu
+		return m, nil
+	case "enter":
+		name := strings.TrimSpace(m.planInput.Value())
+		if name == "" {
+			return m, nil
+		}
+		m.view = viewList
+		if m.report(m.store.CreatePlan(name)) {
+			m.notice = "started plan " + name
+			m.checkPlan()
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.planInput, cmd = m.planInput.Update(msg)
+	return m, cmd
+}
+
+// mergePlan merges a plan into the main branch and goes back to the list.
+func (m Model) mergePlan(plan string) (tea.Model, tea.Cmd) {
+	m.view = viewList
+	conflicts, err := m.store.MergePlan(plan)
+	m.checkPlan()
+	m.loadTasks()
+	m.countConflicts()
+	if !m.report(err) {
+		return m, nil
+	}
+	m.notice = "merged plan " + plan
+	if n := len(conflicts); n > 0 {
+		m.notice += fmt.Sprintf(" with %d conflicts", n)
+	}
+	return m, nil
+}
+
+func (m Model) handlePlanDiffKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "d":
+		m.view = viewPlanMenu
+	case "up", "k":
+		if m.diffCursor > 0 {
+			m.diffCursor--
+		}
+	case "down", "j":
+		if m.diffCursor < len(m.planDiff)-1 {
+			m.diffCursor++
+		}
+	case "m":
+		return m.mergePlan(m.diffPlan)
+	}
+	return m, nil
+}
+
+func (m Model) viewPlanMenuOverlay() string {
+	titleRendered := ui.OverlayTitle.Render("Plans")
+	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(
+		"Enter switch · n new · d diff · m merge · D discard")
+	if m.confirm != nil {
+		hintRendered = m.confirmPrompt()
+	}
+	muted := lipgloss.NewStyle().Foreground(ui.ColorMuted)
+	optStyle := lipgloss.NewStyle().Foreground(ui.ColorFg)
+	selected := lipgloss.NewStyle().Foreground(ui.ColorPrimary).Bold(true)
+
+	options := []string{m.mainBranch}
+	options = append(options, m.plans...)
+	options = append(options, "+ new plan")
+	var rows []string
+	for i, opt := range options {
+		var note string
+		switch {
+		case i == 0 && m.plan == "", i > 0 && i <= len(m.plans) && m.plans[i-1] == m.plan:
+			note = muted.Render(" (current)")
+		case i == 0:
+			note = muted.Render(" (main)")
+		}
+		if i == m.menuCursor {
+			rows = append(rows, selected.Render("▸ "+opt)+note)
+		} else {
+			rows = append(rows, "  "+optStyle.Render(opt)+note)
+		}
+	}
+
+	card := ui.OverlayCard.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			titleRendered,
+			"",
+			strings.Join(rows, "\n"),
+			"",
+			hintRendered,
+		),
+	)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		card,
+	)
+}
+
+func (m Model) viewPlanNameOverlay() string {
+	card := ui.OverlayCard.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			ui.OverlayTitle.Render("New Plan"),
+			"",
+			lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(
+				"Starts from the tasks as they are now"),
+			"",
+			m.planInput.View(),
+			"",
+			lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("Enter to create · Esc to cancel"),
+		),
+	)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		card,
+	)
+}
+
+func (m Model) viewPlanDiffOverlay() string {
+	titleRendered := ui.OverlayTitle.Render(fmt.Sprintf("Plan %s vs %s", m.diffPlan, m.mainBranch))
+	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(
+		"↑/↓ scroll · m merge · Esc back")
+	styles := map[git.ChangeType]lipgloss.Style{
+		git.ChangeAdd:    lipgloss.NewStyle().Foreground(ui.ColorLow),
+		git.ChangeModify: lipgloss.NewStyle().Foreground(ui.ColorMedium),
+		git.ChangeDelete: lipgloss.NewStyle().Foreground(ui.ColorHigh),
+	}
+
+	var rows []string
+	if len(m.planDiff) == 0 {
+		rows = append(rows, lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("No changes"))
+	}
+	start := max(m.diffCursor-maxDiffRows+1, 0)
+	end := min(start+maxDiffRows, len(m.planDiff))
+	for i := start; i < end; i++ {
+		d := m.planDiff[i]
+		line := shorten(d.String(), 60)
+		prefix := "  "
+		if i == m.diffCursor {
+			prefix = "▸ "
+		}
+		rows = append(rows, prefix+styles[d.Type].Render(line))
+	}
+
+	card := ui.OverlayCard.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			titleRendered,
+			"",
+			strings.Join(rows, "\n"),
+			"",
+			hintRendered,
+		),
+	)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		card,
+	)
+}
+
+// planLabel returns the branch shown in the header.
+func (m Model) planLabel() string {
+	if m.plan == "" {
+		return lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(" · ⎇ " + m.mainBranch)
+	}
+	return lipgloss.NewStyle().Foreground(ui.ColorMedium).Render(" · ⎇ plan " + m.plan)
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/user/invar/internal/search"
+	"github.com/user/invar/internal/ui"
+)
+
+// maxSearchResults is how many results the search pane shows.
+const maxSearchResults = 8
+
+func newSearchInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "search content, tags and comments"
+	ti.PromptStyle = lipgloss.NewStyle().Foreground(ui.ColorPrimary)
+	ti.TextStyle = lipgloss.NewStyle().Foreground(ui.ColorFg)
+	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(ui.ColorMuted)
+	return ti
+}
+
+// openSearch shows the search pane, building the index on first use. The
+// index then follows the store's events, so it is never rebuilt.
+func (m Model) openSearch() (tea.Model, tea.Cmd) {
+	if m.index == nil {
+		idx, err := search.Build(m.store, false)
+		if err != nil {
+			return m, nil
+		}
+		idx.Attach(m.store)
+		m.index = idx
+		m.indexHistory = false
+	}
+	m.view = viewSearch
+	m.searchCursor = 0
+	m.searchInput.SetValue("")
+	m.searchInput.Focus()
+	m.runSearch()
+	return m, textinput.Blink
+}
+
+func (m *Model) runSearch() {
+	var results []search.Result
+	for _, r := range m.index.Search(m.searchInput.Value(), 0) {
+		if r.State == search.StateDeleted && !m.searchHistory {
+			continue
+		}
+		results = append(results, r)
+		if len(results) == maxSearchResults {
+			break
+		}
+	}
+	m.results = results
+	if m.searchCursor >= len(m.results) {
+		m.searchCursor = max(len(m.results)-1, 0)
+	}
+}
+
+func (m Model) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.view = viewList
+		m.searchInput.Blur()
+		m.loadTasks()
+		return m, nil
+	case "up", "ctrl+p":
+		if m.searchCursor > 0 {
+			m.searchCursor--
+		}
+		return m, nil
+	case "down", "ctrl+n":
+		if m.searchCursor < len(m.results)-1 {
+			m.searchCursor++
+		}
+		return m, nil
+	case "ctrl+t":
+		m.searchHistory = !m.searchHistory
+		if m.searchHistory && !m.indexHistory {
+			if err := m.index.AddDeleted(m.store); err == nil {
+				m.indexHistory = true
+			}
+		}
+		m.runSearch()
+		return m, nil
+	case "enter":
+		if len(m.results) == 0 {
+			return m, nil
+		}
+		r := m.results[m.searchCursor]
+		switch r.State {
+		case search.StateActive:
+			m.view = viewList
+		case search.StateArchived:
+			m.view = viewArchive
+		case search.StateTrashed:
+			m.view = viewTrash
+		default:
+			return m, nil
+		}
+		m.searchInput.Blur()
+		m.loadTasks()
+		m.selectTask(r.Task.ID)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	m.runSearch()
+	return m, cmd
+}
+
+// selectTask moves the cursor to the task with the given ID, scrolling it
+// into view.
+func (m *Model) selectTask(id string) {
+	for i, t := range m.tasks {
+		if t.ID == id {
+			m.cursor = i
+			break
+		}
+	}
+	vis := m.visibleRowCount()
+	if m.cursor < m.scroll || m.cursor >= m.scroll+vis {
+		m.scroll = max(m.cursor-vis+1, 0)
+	}
+}
+
+func (m Model) viewSearchOverlay() string {
+	titleRendered := ui.OverlayTitle.Render("Search")
+	history := "off"
+	if m.searchHistory {
+		history = "on"
+	}
+	hintRendered := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(
+		fmt.Sprintf("↑/↓ navigate · Enter open · Ctrl+T history: %s · Esc", history))
+
+	var rows []string
+	for i, r := range m.results {
+		state := lipgloss.NewStyle().Foreground(ui.ColorMuted).Render(fmt.Sprintf("%-8s", r.State))
+		content := firstLine(r.Task.Content)
+		if i == m.searchCursor {
+			rows = append(rows, lipgloss.NewStyle().Foreground(ui.ColorPrimary).Bold(true).Render("▸ ")+state+" "+
+				lipgloss.NewStyle().Foreground(ui.ColorPrimary).Bold(true).Render(content))
+		} else {
+			rows = append(rows, "  "+state+" "+lipgloss.NewStyle().Foreground(ui.ColorFg).Render(content))
+		}
+	}
+	if len(rows) == 0 && strings.TrimSpace(m.searchInput.Value()) != "" {
+		rows = append(rows, lipgloss.NewStyle().Foreground(ui.ColorMuted).Render("No matches"))
+	}
+
+	card := ui.OverlayCard.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			titleRendered,
+			"",
+			m.searchInput.View(),
+			"",
+			strings.Join(rows, "\n"),
+			"",
+			hintRendered,
+		),
+	)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		card,
+	)
+}
+
+// shorten cuts s to at most n runes, ending it with an ellipsis if it was
+// longer.
+func shorten(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+func firstLine(s string) string {
+	if lines := splitLines(s); len(lines) > 0 {
+		return lines[0]
+	}
+	return s
+}
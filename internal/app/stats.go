@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/user/invar/internal/stats"
+	"github.com/user/invar/internal/ui"
+)
+
+// statsBarWidth is how many cells the longest bar of a stats chart takes.
+const statsBarWidth = 20
+
+// openStats computes the statistics and shows them.
+func (m Model) openStats() (tea.Model, tea.Cmd) {
+	tasks, err := stats.Collect(m.store)
+	if err != nil {
+		m.notice = "stats failed: " + firstLine(err.Error())
+		return m, nil
+	}
+	m.statTasks = tasks
+	m.stats = stats.Compute(tasks, stats.Options{Weekly: m.statsWeekly}, time.Now())
+	m.view = viewStats
+	return m, nil
+}
+
+func (m Model) handleStatsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "s", "q":
+		m.view = viewList
+		m.statTasks = nil
+	case "w":
+		m.statsWeekly = !m.statsWeekly
+		m.stats = stats.Compute(m.statTasks, stats.Options{Weekly: m.statsWeekly}, time.Now())
+	}
+	return m, nil
+}
+
+func (m Model) viewStatsOverlay() string {
+	r := m.stats
+	muted := lipgloss.NewStyle().Foreground(ui.ColorMuted)
+	fg := lipgloss.NewStyle().Foreground(ui.ColorFg)
+	label := func(s string) string { return fg.Render(fmt.Sprintf("%-10s", s)) }
+
+	var created, completed, open []int
+	var totalCreated, totalCompleted int
+	for _, p := range r.Periods {
+		created = append(created, p.Created)
+		completed = append(completed, p.Completed)
+		open = append(open, p.Open)
+		totalCreated += p.Created
+		totalCompleted += p.Completed
+	}
+	lines := []string{
+		label("Created") + lipgloss.NewStyle().Foreground(ui.ColorPrimary).Render(ui.Sparkline(created)) +
+			muted.Render(fmt.Sprintf("  %d", totalCreated)),
+		label("Completed") + lipgloss.NewStyle().Foreground(ui.ColorLow).Render(ui.Sparkline(completed)) +
+			muted.Render(fmt.Sprintf("  %d", totalCompleted)),
+		label("Open") + lipgloss.NewStyle().Foreground(ui.ColorMedium).Render(ui.Sparkline(open)) +
+			muted.Render(fmt.Sprintf("  %d now", open[len(open)-1])),
+		"",
+	}
+
+	lt := r.LeadTime
+	if lt.Count == 0 {
+		lines = append(lines, label("Lead time")+muted.Render("no tasks completed"))
+	} else {
+		lines = append(lines, label("Lead time")+fg.Render(fmt.Sprintf("median %s · p90 %s",
+			stats.FormatDays(lt.MedianDays), stats.FormatDays(lt.P90Days))))
+		top := 0
+		for _, b := range lt.Histogram {
+			top = max(top, b.Count)
+		}
+		bar := lipgloss.NewStyle().Foreground(ui.ColorPrimary)
+		for _, b := range lt.Histogram {
+			lines = append(lines, muted.Render(fmt.Sprintf("  %-9s ", b.Label))+
+				bar.Render(fmt.Sprintf("%-*s", statsBarWidth, ui.Bar(b.Count, top, statsBarWidth)))+
+				muted.Render(fmt.Sprintf(" %d", b.Count)))
+		}
+	}
+	lines = append(lines, "")
+
+	o := r.Overdue
+	overdue := fmt.Sprintf("%d of %d deadlines missed", o.Late, o.Due)
+	if o.Due > 0 {
+		overdue += fmt.Sprintf(" (%.0f%%)", o.Rate*100)
+	}
+	lines = append(lines, label("Overdue")+fg.Render(overdue), "")
+
+	top := 0
+	for _, p := range r.Priorities {
+		top = max(top, p.Open)
+	}
+	lines = append(lines, label("Open by priority"))
+	priorityStyles := map[string]lipgloss.Style{
+		"high":   lipgloss.NewStyle().Foreground(ui.ColorHigh),
+		"medium": lipgloss.NewStyle().Foreground(ui.ColorMedium),
+		"low":    lipgloss.NewStyle().Foreground(ui.ColorLow),
+	}
+	for _, p := range r.Priorities {
+		lines = append(lines, muted.Render(fmt.Sprintf("  %-9s ", p.Priority))+
+			priorityStyles[string(p.Priority)].Render(fmt.Sprintf("%-*s", statsBarWidth, ui.Bar(p.Open, top, statsBarWidth)))+
+			muted.Render(fmt.Sprintf(" %d", p.Open)))
+	}
+
+	period := "days"
+	if m.statsWeekly {
+		period = "weeks"
+	}
+	card := ui.OverlayCard.Render(
+		lipgloss.JoinVertical(lipgloss.Left,
+			ui.OverlayTitle.Render(fmt.Sprintf("Statistics · last %d %s", len(r.Periods), period)),
+			"",
+			strings.Join(lines, "\n"),
+			"",
+			muted.Render("w days/weeks · Esc close"),
+		),
+	)
+
+	return lipgloss.Place(m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		card,
+	)
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/user/invar/internal/storage"
+)
+
+// syncTimeout bounds a sync started from the TUI.
+const syncTimeout = time.Minute
+
+// autoSyncMsg starts the sync run when the TUI starts. Init cannot start it
+// itself, as Bubble Tea does not keep the model Init would mark as syncing.
+type autoSyncMsg struct{}
+
+// syncDoneMsg reports the end of a background sync.
+type syncDoneMsg struct {
+	store  *storage.Store
+	result storage.SyncResult
+	err    error
+}
+
+// canSync reports whether the current store has a remote, syncing is not
+// turned off and no plan is checked out.
+func (m Model) canSync() bool {
+	if m.cfg.Sync.Offline || m.plan != "" {
+		return false
+	}
+	url, err := m.store.Remote()
+	return err == nil && url != ""
+}
+
+// startSync runs a sync in the background, on a store of its own so the
+// views can keep reading the current one. Keys that change tasks are
+// ignored until it is done.
+func (m Model) startSync() (Model, tea.Cmd) {
+	if m.syncing || !m.canSync() {
+		return m, nil
+	}
+	store := m.store
+	bg, err := store.Reopen()
+	if err != nil {
+		m.notice = "sync failed: " + firstLine(err.Error())
+		return m, nil
+	}
+	m.syncing = true
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		defer cancel()
+		result, err := bg.Sync(ctx)
+		return syncDoneMsg{store: store, result: result, err: err}
+	}
+}
+
+func (m Model) handleSyncDone(msg syncDoneMsg) (tea.Model, tea.Cmd) {
+	m.syncing = false
+	if msg.store != m.store {
+		return m, nil
+	}
+	if msg.result.Pulled {
+		if err := m.store.Reload(); err != nil {
+			m.notice = "reload failed: " + firstLine(err.Error())
+		}
+	}
+	m.syncStatus = m.store.SyncStatus()
+	m.countConflicts()
+	m.checkPlan()
+	if msg.result.Pulled {
+		m.deleted = nil
+		m.loadTasks()
+		return m, m.checkIntegrity()
+	}
+	return m, nil
+}
+
+// countConflicts counts the merge conflicts waiting for the user, so the
+// dashboard can warn about them.
+func (m *Model) countConflicts() {
+	conflicts, _ := m.store.Conflicts()
+	m.conflicts = len(conflicts)
+}
+
+// syncLabel describes the sync state for the footer, or "" if the store
+// has never been synced and has no remote.
+func (m Model) syncLabel() string {
+	switch {
+	case m.syncing:
+		return "⇅ syncing…"
+	case m.cfg.Sync.Offline:
+		return "⇅ offline"
+	case m.syncStatus.Error != "":
+		return "⇅ sync failed"
+	case !m.syncStatus.LastSuccess.IsZero():
+		return "⇅ synced " + ago(m.syncStatus.LastSuccess)
+	case m.canSync():
+		return "⇅ not synced"
+	}
+	return ""
+}
+
+func ago(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return t.Format("Jan 02")
+}
+
+// Store returns the store the TUI is showing, which changes when the user
+// switches profiles.
+func (m Model) Store() *storage.Store {
+	return m.store
+}
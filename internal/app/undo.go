@@ -0,0 +1,43 @@
+package app
+
+import (
+	"errors"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/user/invar/internal/storage"
+)
+
+// undo reverts the newest operation on the undo stack and reloads the view.
+// The stack is read from the history only now, not after every change.
+func (m Model) undo() (tea.Model, tea.Cmd) {
+	subject, err := m.store.Undo()
+	if errors.Is(err, storage.ErrNothingToUndo) {
+		m.notice = "nothing to undo"
+		return m, nil
+	}
+	if err != nil {
+		m.notice = "undo failed: " + firstLine(err.Error())
+		return m, nil
+	}
+	m.deleted = nil
+	m.loadTasks()
+	m.notice = "undid " + subject
+	return m, nil
+}
+
+// redo reapplies the newest undone operation.
+func (m Model) redo() (tea.Model, tea.Cmd) {
+	subject, err := m.store.Redo()
+	if errors.Is(err, storage.ErrNothingToRedo) {
+		m.notice = "nothing to redo"
+		return m, nil
+	}
+	if err != nil {
+		m.notice = "redo failed: " + firstLine(err.Error())
+		return m, nil
+	}
+	m.deleted = nil
+	m.loadTasks()
+	m.notice = "redid " + subject
+	return m, nil
+}
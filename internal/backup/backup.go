@@ -0,0 +1,256 @@
+// Package backup writes and restores self-contained snapshots of a data
+// dir: a gzipped tar with a manifest, the task files as plain files and the
+// repo history as a git bundle.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/storage"
+)
+
+// Version is the archive layout written by Create.
+const Version = 1
+
+// Names of the entries in an archive. Task files live under filesDir with
+// their paths relative to the data dir.
+const (
+	manifestName = "manifest.json"
+	bundleName   = "repo.bundle"
+	filesDir     = "files"
+)
+
+// Manifest describes an archive. It is the first entry, so an archive can
+// be identified without reading it all.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Profile   string    `json:"profile,omitempty"`
+	DataDir   string    `json:"data_dir"`
+	Format    string    `json:"format"`
+	Encrypted bool      `json:"encrypted"`
+	Head      string    `json:"head"`
+	Files     []File    `json:"files"`
+}
+
+// File is an archive entry with its size and SHA-256 checksum.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Tasks returns the number of task files in the archive, trashed ones
+// included.
+func (m *Manifest) Tasks() int {
+	n := 0
+	for _, f := range m.Files {
+		rel, ok := strings.CutPrefix(f.Path, filesDir+"/")
+		if !ok {
+			continue
+		}
+		dir, name := path.Split(rel)
+		ext := path.Ext(name)
+		if (dir == "" || dir == "trash/") && (ext == ".json" || ext == ".md") {
+			n++
+		}
+	}
+	return n
+}
+
+// Create writes an archive of the store's data dir to w. The store does
+// not need to be unlocked: encrypted task files are archived as they are.
+func Create(w io.Writer, s *storage.Store, profile string) (*Manifest, error) {
+	head, err := s.Head()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Profile:   profile,
+		DataDir:   s.DataDir(),
+		Format:    string(s.Format()),
+		Encrypted: s.Encrypted(),
+		Head:      head,
+	}
+
+	contents := map[string][]byte{}
+	add := func(name string, data []byte) {
+		sum := sha256.Sum256(data)
+		m.Files = append(m.Files, File{Path: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+		contents[name] = data
+	}
+
+	root := s.DataDir()
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		add(filesDir+"/"+filepath.ToSlash(rel), data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if head != "" {
+		var bundle bytes.Buffer
+		if err := s.WriteBundle(&bundle); err != nil {
+			return nil, fmt.Errorf("writing git bundle: %w", err)
+		}
+		add(bundleName, bundle.Bytes())
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	entries := append([]File{{Path: manifestName}}, m.Files...)
+	for _, f := range entries {
+		data := contents[f.Path]
+		if f.Path == manifestName {
+			data = manifest
+		}
+		hdr := &tar.Header{
+			Name:    f.Path,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: m.CreatedAt,
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gz.Close()
+}
+
+// WriteFile writes an archive to filename, along with a filename.sha256
+// checksum file in the format of sha256sum.
+func WriteFile(filename string, s *storage.Store, profile string) (*Manifest, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	tmp := filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	h := sha256.New()
+	m, err := Create(io.MultiWriter(f, h), s, profile)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		return nil, err
+	}
+	sum := fmt.Sprintf("%x  %s\n", h.Sum(nil), filepath.Base(filename))
+	return m, os.WriteFile(filename+".sha256", []byte(sum), 0644)
+}
+
+// Name returns the archive file name for a backup of profile taken at t.
+func Name(profile string, t time.Time) string {
+	return prefix(profile) + t.Format("20060102-150405") + ".tar.gz"
+}
+
+func prefix(profile string) string {
+	if profile == "" {
+		profile = "data"
+	}
+	return "invar-" + profile + "-"
+}
+
+// List returns the archives of profile in dir, oldest first.
+func List(dir, profile string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var archives []string
+	for _, e := range entries {
+		name := e.Name()
+		rest, ok := strings.CutPrefix(name, prefix(profile))
+		// The timestamp keeps "work" from matching "work-old" archives.
+		if !ok || !strings.HasSuffix(rest, ".tar.gz") || len(rest) != len("20060102-150405.tar.gz") {
+			continue
+		}
+		archives = append(archives, filepath.Join(dir, name))
+	}
+	sort.Strings(archives)
+	return archives, nil
+}
+
+// Rotate deletes all but the newest keep archives of profile in dir,
+// together with their checksum files, and returns the deleted archives.
+func Rotate(dir, profile string, keep int) ([]string, error) {
+	archives, err := List(dir, profile)
+	if err != nil || len(archives) <= keep {
+		return nil, err
+	}
+	old := archives[:len(archives)-keep]
+	for _, a := range old {
+		if err := os.Remove(a); err != nil {
+			return nil, err
+		}
+		os.Remove(a + ".sha256")
+	}
+	return old, nil
+}
+
+// Due reports whether the newest archive of profile in dir is older than
+// interval, or there is none.
+func Due(dir, profile string, interval time.Duration) (bool, error) {
+	archives, err := List(dir, profile)
+	if err != nil || len(archives) == 0 {
+		return true, err
+	}
+	info, err := os.Stat(archives[len(archives)-1])
+	if err != nil {
+		return false, err
+	}
+	return time.Since(info.ModTime()) >= interval, nil
+}
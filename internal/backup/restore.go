@@ -0,0 +1,202 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/git"
+)
+
+// ErrNotEmpty is returned by Restore when the target dir already holds
+// data and force is not set.
+var ErrNotEmpty = errors.New("target directory is not empty")
+
+// Archive is a validated backup, read fully into memory.
+type Archive struct {
+	Manifest *Manifest
+	contents map[string][]byte
+}
+
+// Open reads and validates the archive in filename. If a filename.sha256
+// file exists the archive must match it, and every entry must match the
+// checksum the manifest records for it.
+func Open(filename string) (*Archive, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySum(filename, data); err != nil {
+		return nil, err
+	}
+	a, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return a, nil
+}
+
+func verifySum(filename string, data []byte) error {
+	sumFile, err := os.ReadFile(filename + ".sha256")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	want, _, _ := strings.Cut(strings.TrimSpace(string(sumFile)), " ")
+	got := sha256.Sum256(data)
+	if !strings.EqualFold(want, hex.EncodeToString(got[:])) {
+		return fmt.Errorf("%s: checksum mismatch, the archive is damaged", filename)
+	}
+	return nil
+}
+
+// Read reads and validates an archive.
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an invar backup: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	a := &Archive{contents: map[string][]byte{}}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == manifestName {
+			a.Manifest = &Manifest{}
+			if err := json.Unmarshal(data, a.Manifest); err != nil {
+				return nil, fmt.Errorf("%s: %w", manifestName, err)
+			}
+			continue
+		}
+		a.contents[hdr.Name] = data
+	}
+
+	m := a.Manifest
+	if m == nil {
+		return nil, errors.New("not an invar backup: no manifest")
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("archive version %d is newer than this invar supports", m.Version)
+	}
+	for _, f := range m.Files {
+		if !safePath(f.Path) {
+			return nil, fmt.Errorf("unsafe path %q", f.Path)
+		}
+		data, ok := a.contents[f.Path]
+		if !ok {
+			return nil, fmt.Errorf("%s is missing", f.Path)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			return nil, fmt.Errorf("%s: checksum mismatch", f.Path)
+		}
+	}
+	if m.Head != "" && a.contents[bundleName] == nil {
+		return nil, fmt.Errorf("%s is missing", bundleName)
+	}
+	return a, nil
+}
+
+func safePath(p string) bool {
+	clean := path.Clean(p)
+	return clean == p && !path.IsAbs(p) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// Restore writes the archive to dir, which becomes a data dir with the
+// archived task files and history. A dir that exists and is not empty is
+// only replaced with force, and is then kept as dir.bak-<timestamp>, whose
+// path is returned.
+func (a *Archive) Restore(dir string, force bool) (string, error) {
+	dir = filepath.Clean(dir)
+	empty, err := isEmpty(dir)
+	if err != nil {
+		return "", err
+	}
+	if !empty && !force {
+		return "", ErrNotEmpty
+	}
+
+	// Build the data dir next to its final place, so a failed restore
+	// leaves the existing one alone.
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+".restore-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return "", err
+	}
+
+	for _, f := range a.Manifest.Files {
+		rel, ok := strings.CutPrefix(f.Path, filesDir+"/")
+		if !ok {
+			continue
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(target, a.contents[f.Path], 0644); err != nil {
+			return "", err
+		}
+	}
+	if bundle := a.contents[bundleName]; bundle != nil {
+		if _, err := git.InitFromBundle(tmp, bufio.NewReader(bytes.NewReader(bundle))); err != nil {
+			return "", fmt.Errorf("restoring history: %w", err)
+		}
+	}
+
+	var moved string
+	if _, err := os.Stat(dir); err == nil {
+		if !empty {
+			moved = fmt.Sprintf("%s.bak-%s", dir, time.Now().Format("20060102-150405"))
+			if err := os.Rename(dir, moved); err != nil {
+				return "", err
+			}
+		} else if err := os.Remove(dir); err != nil {
+			return "", err
+		}
+	}
+	return moved, os.Rename(tmp, dir)
+}
+
+func isEmpty(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the name of the built-in profile that maps to the
+// default data dir unless the config file overrides it.
+const DefaultProfile = "default"
+
+// Config is the user configuration, read from config.json in the invar
+// config dir. A missing file yields an empty config.
+type Config struct {
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+	Backup         Backup             `json:"backup,omitzero"`
+	Retention      Retention          `json:"retention,omitzero"`
+	Sync           Sync               `json:"sync,omitzero"`
+	Signing        Signing            `json:"signing,omitzero"`
+	Hooks          Hooks              `json:"hooks,omitzero"`
+}
+
+// Hooks configures the hook scripts run on task changes. Dir defaults to
+// the hooks dir in the config dir, and a TimeoutSeconds of 0 uses the
+// default timeout.
+type Hooks struct {
+	Dir            string `json:"dir,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// Signing configures commit signing. Format is "openpgp" or "ssh" and Key
+// the private key file. Trusted lists public key files whose signatures
+// `invar verify` accepts, besides the signing key's own. Passphrase is
+// never stored; it comes from INVAR_SIGNING_PASSPHRASE.
+type Signing struct {
+	Format     string   `json:"format,omitempty"`
+	Key        string   `json:"key,omitempty"`
+	Trusted    []string `json:"trusted,omitempty"`
+	Passphrase string   `json:"-"`
+}
+
+// KeyPath returns the signing key file with ~ expanded.
+func (s Signing) KeyPath() string {
+	return expandHome(s.Key)
+}
+
+// TrustedPaths returns the trusted key files with ~ expanded.
+func (s Signing) TrustedPaths() []string {
+	var paths []string
+	for _, p := range s.Trusted {
+		paths = append(paths, expandHome(p))
+	}
+	return paths
+}
+
+// Sync controls syncing with the data dir's git remote. With Auto the TUI
+// syncs on start and quit; Offline turns all syncing off.
+type Sync struct {
+	Auto    bool `json:"auto,omitempty"`
+	Offline bool `json:"offline,omitempty"`
+}
+
+// Retention holds the rules `invar maintenance` and the TUI apply on start.
+// A rule set to 0 days is off.
+type Retention struct {
+	ArchiveCompletedAfterDays int `json:"archive_completed_after_days,omitempty"`
+	PurgeArchivedAfterDays    int `json:"purge_archived_after_days,omitempty"`
+	EmptyTrashAfterDays       int `json:"empty_trash_after_days,omitempty"`
+}
+
+// Backup configures where `invar backup` writes archives and how often the
+// TUI takes one on start. An IntervalHours of 0 disables automatic backups,
+// and a Keep of 0 keeps every archive.
+type Backup struct {
+	Dir           string `json:"dir,omitempty"`
+	IntervalHours int    `json:"interval_hours,omitempty"`
+	Keep          int    `json:"keep,omitempty"`
+}
+
+// Profile is a named data dir, such as "work" or "personal".
+type Profile struct {
+	DataDir string `json:"data_dir,omitempty"`
+}
+
+// Location identifies the data dir in use and the profile it came from.
+// Profile is empty when the dir was given directly by flag or environment.
+type Location struct {
+	Profile string
+	DataDir string
+}
+
+// Dir returns the invar config dir, honouring XDG_CONFIG_HOME.
+func Dir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "invar")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".config", "invar")
+}
+
+// Path returns the location of the config file.
+func Path() string {
+	return filepath.Join(Dir(), "config.json")
+}
+
+// dataHome returns the invar data root, honouring XDG_DATA_HOME.
+func dataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "invar")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".local", "share", "invar")
+}
+
+// DefaultDataDir returns the data dir used when nothing else is configured.
+func DefaultDataDir() string {
+	return filepath.Join(dataHome(), "tasks")
+}
+
+func Load() (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(Path())
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", Path(), err)
+	}
+	return cfg, nil
+}
+
+// Save writes the config back to the config file.
+func (c *Config) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(Path(), append(data, '\n'), 0644)
+}
+
+// AddProfile adds a profile using the default location for its name.
+func (c *Config) AddProfile(name string) error {
+	if _, ok := c.Profiles[name]; ok || name == DefaultProfile {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	c.Profiles[name] = Profile{}
+	return nil
+}
+
+// BackupDir returns the dir backups are written to.
+func (c *Config) BackupDir() string {
+	if c.Backup.Dir != "" {
+		return expandHome(c.Backup.Dir)
+	}
+	return filepath.Join(dataHome(), "backups")
+}
+
+// HooksDir returns the dir hook scripts are read from.
+func (c *Config) HooksDir() string {
+	if c.Hooks.Dir != "" {
+		return expandHome(c.Hooks.Dir)
+	}
+	return filepath.Join(Dir(), "hooks")
+}
+
+// ProfileNames returns the configured profile names in sorted order,
+// always including the default profile.
+func (c *Config) ProfileNames() []string {
+	names := []string{DefaultProfile}
+	for name := range c.Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Location returns the data dir for the named profile. Profiles without an
+// explicit data_dir live under the XDG data dir.
+func (c *Config) Location(profile string) (Location, error) {
+	p, ok := c.Profiles[profile]
+	if !ok && profile != DefaultProfile {
+		return Location{}, fmt.Errorf("unknown profile %q", profile)
+	}
+	dir := expandHome(p.DataDir)
+	if dir == "" {
+		if profile == DefaultProfile {
+			dir = DefaultDataDir()
+		} else {
+			dir = filepath.Join(dataHome(), "profiles", profile)
+		}
+	}
+	return Location{Profile: profile, DataDir: dir}, nil
+}
+
+// Resolve picks the data dir to use. An explicit dir wins, then an explicit
+// profile, then INVAR_DIR, INVAR_PROFILE and the configured default profile,
+// in that order.
+func (c *Config) Resolve(dataDir, profile string) (Location, error) {
+	if dataDir != "" {
+		return Location{DataDir: expandHome(dataDir)}, nil
+	}
+	if profile != "" {
+		return c.Location(profile)
+	}
+	if dir := os.Getenv("INVAR_DIR"); dir != "" {
+		return Location{DataDir: expandHome(dir)}, nil
+	}
+
+	profile = os.Getenv("INVAR_PROFILE")
+	if profile == "" {
+		profile = c.DefaultProfile
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	return c.Location(profile)
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, path[1:])
+	}
+	return path
+}
@@ -0,0 +1,98 @@
+package crypt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheTTL bounds how long a cached key is trusted when the cache lives in
+// a temp dir that, unlike XDG_RUNTIME_DIR, survives logout.
+const cacheTTL = 12 * time.Hour
+
+// ErrUnsafeCache is returned when the key cache dir could be read by
+// someone else.
+var ErrUnsafeCache = errors.New("key cache dir is not private")
+
+func cacheDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "invar")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("invar-%d", os.Getuid()))
+}
+
+func cachePath(dataDir string) string {
+	abs, err := filepath.Abs(dataDir)
+	if err != nil {
+		abs = dataDir
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(cacheDir(), hex.EncodeToString(sum[:8])+".key")
+}
+
+// privateCacheDir creates the cache dir if needed and checks that it is a
+// directory only the current user can get into. Without XDG_RUNTIME_DIR it
+// sits at a predictable path in a shared temp dir, where another user could
+// have made it first.
+func privateCacheDir() error {
+	dir := cacheDir()
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0700 || !ownedByUser(info) {
+		return fmt.Errorf("%w: %s must be a directory owned by you with mode 0700", ErrUnsafeCache, dir)
+	}
+	return nil
+}
+
+// CacheKey stores the derived key for dataDir so later invocations in the
+// same session do not have to ask for the passphrase again.
+func CacheKey(dataDir string, k *Key) error {
+	if err := privateCacheDir(); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath(dataDir), []byte(hex.EncodeToString(k.raw)), 0600)
+}
+
+// CachedKey returns the cached key for dataDir, or nil if there is none, it
+// has expired or the cache dir is not private.
+func CachedKey(dataDir string) *Key {
+	if privateCacheDir() != nil {
+		return nil
+	}
+	path := cachePath(dataDir)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > cacheTTL {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	raw, err := hex.DecodeString(string(data))
+	if err != nil {
+		return nil
+	}
+	k, err := newKey(raw)
+	if err != nil {
+		return nil
+	}
+	return k
+}
+
+// ForgetKey removes the cached key for dataDir, if any.
+func ForgetKey(dataDir string) error {
+	err := os.Remove(cachePath(dataDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// prefix marks an encrypted file. The rest of the file is the base64 of
+// nonce||ciphertext, so encrypted task files stay plain text for git.
+const prefix = "invar:v2:"
+
+// version is the format of the params and the files sealed with them,
+// which authenticate the name of the file with the ciphertext.
+const version = 2
+
+// checkText is sealed into the params so a wrong passphrase is detected
+// before any task file is touched.
+const checkText = "invar"
+
+var (
+	ErrWrongKey  = errors.New("wrong passphrase or key file")
+	ErrNotSealed = errors.New("data is not encrypted")
+)
+
+// Params describe how the key for a data dir is derived. They are stored
+// alongside the tasks so every clone of the repo can derive the same key.
+type Params struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Check   string `json:"check"`
+}
+
+// Key is a derived 256-bit AES-GCM key.
+type Key struct {
+	raw  []byte
+	aead cipher.AEAD
+}
+
+// NewParams returns params with a fresh random salt. The check value is
+// filled in by Seal once a key has been derived.
+func NewParams() (*Params, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &Params{Version: version, KDF: "scrypt", Salt: salt, N: 1 << 15, R: 8, P: 1}, nil
+}
+
+func LoadParams(path string) (*Params, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Params
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Version != version {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, p.Version)
+	}
+	if p.KDF != "scrypt" {
+		return nil, fmt.Errorf("%s: unsupported kdf %q", path, p.KDF)
+	}
+	return &p, nil
+}
+
+func (p *Params) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Derive turns a passphrase or the contents of a key file into a key.
+func (p *Params) Derive(secret []byte) (*Key, error) {
+	raw, err := scrypt.Key(secret, p.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(raw)
+}
+
+// SetCheck records a check value for the key so Verify can recognise it.
+func (p *Params) SetCheck(k *Key) error {
+	sealed, err := k.Seal([]byte(checkText), checkText)
+	if err != nil {
+		return err
+	}
+	p.Check = string(sealed)
+	return nil
+}
+
+// Verify reports ErrWrongKey unless k was derived from the right secret.
+func (p *Params) Verify(k *Key) error {
+	plain, err := k.Open([]byte(p.Check), checkText)
+	if err != nil || string(plain) != checkText {
+		return ErrWrongKey
+	}
+	return nil
+}
+
+func newKey(raw []byte) (*Key, error) {
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{raw: raw, aead: aead}, nil
+}
+
+// Seal encrypts plain. The name is authenticated along with it, so Open
+// fails unless it is given the same name: sealing a task file with its
+// name keeps it from being passed off as another task's.
+func (k *Key) Seal(plain []byte, name string) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := k.aead.Seal(nonce, nonce, plain, []byte(name))
+	return []byte(prefix + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// Open decrypts data sealed under the given name.
+func (k *Key) Open(data []byte, name string) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, ErrNotSealed
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(prefix):])))
+	if err != nil {
+		return nil, err
+	}
+	n := k.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrWrongKey
+	}
+	plain, err := k.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+// IsSealed reports whether data was produced by Key.Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(prefix))
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package crypt
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByUser reports whether the file belongs to the current user.
+func ownedByUser(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
@@ -0,0 +1,201 @@
+package date
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSuggestions is how many corrections a ParseError offers at most.
+const maxSuggestions = 3
+
+// ParseError is returned for input ParseAt does not understand. Reason
+// says what is wrong, and Suggestions holds corrected inputs that do
+// parse, closest first.
+type ParseError struct {
+	Input       string
+	Reason      string
+	Suggestions []string
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("invalid date %q", e.Input)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	if len(e.Suggestions) > 0 {
+		quoted := make([]string, len(e.Suggestions))
+		for i, s := range e.Suggestions {
+			quoted[i] = strconv.Quote(s)
+		}
+		msg += " (did you mean " + strings.Join(quoted, " or ") + "?)"
+	}
+	return msg
+}
+
+// keywords are the words of the grammar besides weekdays, months, units
+// and fillers.
+var keywords = []string{
+	"today", "tomorrow", "tmr", "tmrw", "next", "this", "week", "month", "year",
+	"in", "a", "an", "of", "end", "day", "eod", "eow", "eom", "eoy",
+	"noon", "midday", "midnight", "am", "pm", "none",
+}
+
+// vocabulary is every word the grammar knows, sorted.
+var vocabulary = words()
+
+func words() []string {
+	words := slices.Clone(keywords)
+	for w := range weekdays {
+		words = append(words, w)
+	}
+	for w := range months {
+		words = append(words, w)
+	}
+	for w := range units {
+		words = append(words, w)
+	}
+	for w := range fillers {
+		words = append(words, w)
+	}
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// explain works out why tokens did not parse.
+func explain(input string, tokens []string, now time.Time) *ParseError {
+	e := &ParseError{Input: input}
+	for _, tok := range tokens {
+		if known(tok) {
+			continue
+		}
+		e.Reason = fmt.Sprintf("unknown word %q", tok)
+		for _, word := range closest(tok) {
+			fixed := replaceWord(input, tok, word)
+			if d, err := ParseAt(fixed, now); err == nil && d != nil && !slices.Contains(e.Suggestions, fixed) {
+				e.Suggestions = append(e.Suggestions, fixed)
+			}
+			if len(e.Suggestions) == maxSuggestions {
+				break
+			}
+		}
+		return e
+	}
+	for i, tok := range tokens {
+		month, ok := months[tok]
+		if !ok {
+			continue
+		}
+		for _, j := range []int{i - 1, i + 1, i - 2} {
+			if j < 0 || j >= len(tokens) {
+				continue
+			}
+			day, ok := parseDayOfMonth(tokens[j], false)
+			if !ok {
+				continue
+			}
+			if day > maxDays(month) {
+				e.Reason = fmt.Sprintf("%s has no day %d", month, day)
+				return e
+			}
+			for _, tok := range tokens {
+				year, err := strconv.Atoi(tok)
+				if err == nil && len(tok) == 4 && day > daysIn(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)) {
+					e.Reason = fmt.Sprintf("%s %d has no day %d", month, year, day)
+					return e
+				}
+			}
+		}
+	}
+	return e
+}
+
+// known reports whether tok is a word of the grammar or a number, time or
+// date it can read.
+func known(tok string) bool {
+	if _, err := strconv.Atoi(strings.TrimPrefix(tok, "+")); err == nil {
+		return true
+	}
+	if _, _, ok := parseClock(tok); ok {
+		return true
+	}
+	if _, ok := parseDayOfMonth(tok, true); ok {
+		return true
+	}
+	if _, _, ok := parseOffset([]string{tok}); ok {
+		return true
+	}
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, tok); err == nil {
+			return true
+		}
+	}
+	_, ok := slices.BinarySearch(vocabulary, tok)
+	return ok
+}
+
+// closest returns the words of the grammar within a small edit distance
+// of tok, nearest first.
+func closest(tok string) []string {
+	limit := 2
+	if len(tok) <= 3 {
+		limit = 1
+	}
+	type match struct {
+		word string
+		dist int
+	}
+	var matches []match
+	for _, word := range vocabulary {
+		if d := distance(tok, word); d <= limit {
+			matches = append(matches, match{word, d})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return a.dist - b.dist })
+	words := make([]string, len(matches))
+	for i, m := range matches {
+		words[i] = m.word
+	}
+	return words
+}
+
+// replaceWord swaps the first whole word of input equal to old, ignoring
+// case, for word.
+func replaceWord(input, old, word string) string {
+	fields := strings.Fields(input)
+	for i, f := range fields {
+		if strings.EqualFold(strings.Trim(f, ","), old) {
+			fields[i] = strings.Replace(strings.ToLower(f), old, word, 1)
+			break
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// maxDays returns the most days month can have.
+func maxDays(month time.Month) int {
+	return daysIn(time.Date(2000, month, 1, 0, 0, 0, 0, time.UTC))
+}
@@ -1,60 +1,403 @@
 package date
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// fillers are words the grammar skips, so "on the 15th" and "friday at
+// 3pm" read the same as "15th" and "friday 3pm".
+var fillers = map[string]bool{"on": true, "at": true, "the": true, "by": true}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// units maps the spellings of a relative offset's unit to its canonical
+// name.
+var units = map[string]string{
+	"min": "minute", "mins": "minute", "minute": "minute", "minutes": "minute",
+	"h": "hour", "hr": "hour", "hrs": "hour", "hour": "hour", "hours": "hour",
+	"d": "day", "day": "day", "days": "day",
+	"w": "week", "wk": "week", "wks": "week", "week": "week", "weeks": "week",
+	"mo": "month", "month": "month", "months": "month",
+	"y": "year", "yr": "year", "yrs": "year", "year": "year", "years": "year",
+}
+
+// layouts are the numeric dates ParseAt accepts.
+var layouts = []string{"2006-01-02", "2006/01/02", "01-02-2006", "01/02/2006"}
+
+// Parse reads a deadline relative to the current time; see ParseAt.
 func Parse(input string) (*time.Time, error) {
-	input = strings.TrimSpace(strings.ToLower(input))
+	return ParseAt(input, time.Now())
+}
 
-	if input == "none" || input == "" {
+// ParseAt reads a deadline typed by hand, relative to now. It returns nil
+// for "none" and an empty input, and a *ParseError for anything it does not
+// understand.
+//
+// The input is a day, a time of day, or both in either order:
+//
+//	days:  today, tomorrow, a weekday ("fri", "next friday"), "next week",
+//	       "next month", "in 2 weeks", "+5d", "eod", "eow", "end of month",
+//	       "eoy", "the 15th", "march 3", "3rd of march 2027", 2006-01-02,
+//	       01/02/2006
+//	times: 3pm, 3:30pm, 14:00, noon, midnight
+//
+// "in 2 hours" and "+30min" give a day and a time at once. A day without a
+// time is due at 23:59, except next week, which starts at 9:00. A time
+// without a day is the next time the clock shows it. Weekdays, ordinals and
+// dates without a year mean the next one to come, today included, and
+// "next friday" is the Friday of next week.
+func ParseAt(input string, now time.Time) (*time.Time, error) {
+	input = strings.TrimSpace(input)
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return &t, nil
+	}
+	if input == "" || strings.EqualFold(input, "none") {
 		return nil, nil
 	}
+	tokens := tokenize(input)
 
-	now := time.Now()
+	var (
+		hour, minute int
+		hasClock     bool
+		rest         []string
+	)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		// "3 pm" is the same as "3pm".
+		merged := i+1 < len(tokens) && (tokens[i+1] == "am" || tokens[i+1] == "pm")
+		if merged {
+			tok += tokens[i+1]
+		}
+		if h, m, ok := parseClock(tok); ok {
+			if hasClock {
+				return nil, &ParseError{Input: input, Reason: "more than one time of day"}
+			}
+			hour, minute, hasClock = h, m, true
+			if merged {
+				i++
+			}
+			continue
+		}
+		rest = append(rest, tokens[i])
+	}
 
-	switch input {
-	case "today":
-		d := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 0, 0, now.Location())
-		return &d, nil
-	case "tomorrow":
-		tomorrow := now.AddDate(0, 0, 1)
-		d := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 23, 59, 0, 0, now.Location())
+	today := startOfDay(now)
+	if len(rest) == 0 {
+		if !hasClock {
+			return nil, explain(input, tokens, now)
+		}
+		d := at(today, hour, minute)
+		if !d.After(now) {
+			d = d.AddDate(0, 0, 1)
+		}
 		return &d, nil
+	}
+	d, exact, ok := parseDay(rest, now, today)
+	if !ok {
+		return nil, explain(input, tokens, now)
+	}
+	if exact && hasClock {
+		return nil, &ParseError{Input: input, Reason: fmt.Sprintf("%q already sets the time", strings.Join(rest, " "))}
+	}
+	if hasClock {
+		d = at(d, hour, minute)
+	}
+	return &d, nil
+}
+
+// tokenize lowercases input and splits it into words, dropping commas and
+// filler words.
+func tokenize(input string) []string {
+	var tokens []string
+	for _, tok := range strings.Fields(strings.ToLower(strings.ReplaceAll(input, ",", " "))) {
+		if !fillers[tok] {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+// parseClock reads a time of day: "noon", "midnight", "3pm", "3:30am" or
+// 24-hour "14:00". A bare number is not a time, so it can be a day.
+func parseClock(tok string) (hour, minute int, ok bool) {
+	switch tok {
+	case "noon", "midday":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+	meridiem := ""
+	if s, found := strings.CutSuffix(tok, "am"); found {
+		tok, meridiem = s, "am"
+	} else if s, found := strings.CutSuffix(tok, "pm"); found {
+		tok, meridiem = s, "pm"
+	}
+	hs, ms, hasMinutes := strings.Cut(tok, ":")
+	if !hasMinutes && meridiem == "" {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(hs)
+	if err != nil || len(hs) > 2 {
+		return 0, 0, false
+	}
+	if hasMinutes {
+		if len(ms) != 2 {
+			return 0, 0, false
+		}
+		if minute, err = strconv.Atoi(ms); err != nil || minute > 59 {
+			return 0, 0, false
+		}
+	}
+	switch meridiem {
+	case "":
+		if hour > 23 {
+			return 0, 0, false
+		}
+	default:
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	return hour, minute, true
+}
+
+// parseDay reads the day part of a deadline. Exact is set when it names a
+// moment rather than a day, as "in 2 hours" does, so no time may be added.
+func parseDay(tokens []string, now, today time.Time) (d time.Time, exact, ok bool) {
+	switch strings.Join(tokens, " ") {
+	case "today", "eod", "end of day":
+		return endOfDay(today), false, true
+	case "tomorrow", "tmr", "tmrw":
+		return endOfDay(today.AddDate(0, 0, 1)), false, true
 	case "next week":
-		nextWeek := now.AddDate(0, 0, 7)
-		d := time.Date(nextWeek.Year(), nextWeek.Month(), nextWeek.Day(), 9, 0, 0, 0, now.Location())
-		return &d, nil
-	case "in 3 days":
-		future := now.AddDate(0, 0, 3)
-		d := time.Date(future.Year(), future.Month(), future.Day(), 23, 59, 0, 0, now.Location())
-		return &d, nil
-	case "in a week":
-		future := now.AddDate(0, 0, 7)
-		d := time.Date(future.Year(), future.Month(), future.Day(), 23, 59, 0, 0, now.Location())
-		return &d, nil
+		return at(today.AddDate(0, 0, 7), 9, 0), false, true
+	case "next month":
+		return endOfDay(addMonths(today, 1)), false, true
+	case "next year":
+		return endOfDay(addMonths(today, 12)), false, true
+	case "eow", "end of week":
+		return endOfDay(today.AddDate(0, 0, (7-int(today.Weekday()))%7)), false, true
+	case "eom", "end of month":
+		return endOfDay(time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location())), false, true
+	case "eoy", "end of year":
+		return endOfDay(time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, today.Location())), false, true
+	}
+
+	switch {
+	case len(tokens) == 1 && isWeekday(tokens[0]), len(tokens) == 2 && tokens[0] == "this" && isWeekday(tokens[1]):
+		wd := weekdays[tokens[len(tokens)-1]]
+		return endOfDay(today.AddDate(0, 0, (int(wd)-int(today.Weekday())+7)%7)), false, true
+	case len(tokens) == 2 && tokens[0] == "next" && isWeekday(tokens[1]):
+		monday := today.AddDate(0, 0, 7-(int(today.Weekday())+6)%7)
+		return endOfDay(monday.AddDate(0, 0, (int(weekdays[tokens[1]])+6)%7)), false, true
+	}
+
+	if n, unit, ok := parseOffset(tokens); ok {
+		switch unit {
+		case "minute":
+			return now.Add(time.Duration(n) * time.Minute).Truncate(time.Minute), true, true
+		case "hour":
+			return now.Add(time.Duration(n) * time.Hour).Truncate(time.Minute), true, true
+		case "day":
+			return endOfDay(today.AddDate(0, 0, n)), false, true
+		case "week":
+			return endOfDay(today.AddDate(0, 0, 7*n)), false, true
+		case "month":
+			return endOfDay(addMonths(today, n)), false, true
+		case "year":
+			return endOfDay(addMonths(today, 12*n)), false, true
+		}
 	}
 
-	formats := []string{
-		"2006-01-02",
-		"2006-01-02 15:04",
-		"01-02-2006",
-		"01/02/2006",
-		"Jan 2",
-		"Jan 2 2006",
-		"January 2",
-		"January 2 2006",
+	if len(tokens) == 1 {
+		for _, layout := range layouts {
+			if t, err := time.ParseInLocation(layout, tokens[0], today.Location()); err == nil {
+				return endOfDay(t), false, true
+			}
+		}
+		if day, ok := parseDayOfMonth(tokens[0], true); ok {
+			return nextDayOfMonth(today, day)
+		}
 	}
+	return parseMonthDay(tokens, today)
+}
 
-	for _, format := range formats {
-		if t, err := time.Parse(format, input); err == nil {
-			if t.Year() == 0 {
-				t = t.AddDate(now.Year(), 0, 0)
+// parseOffset reads a relative offset: "in 3 days", "in a week", "2 weeks",
+// "+5d" or "+5 days".
+func parseOffset(tokens []string) (n int, unit string, ok bool) {
+	if len(tokens) > 0 && tokens[0] == "in" {
+		tokens = tokens[1:]
+	}
+	switch len(tokens) {
+	case 1:
+		s, found := strings.CutPrefix(tokens[0], "+")
+		if !found {
+			return 0, "", false
+		}
+		i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return 0, "", false
+		}
+		n, _ = strconv.Atoi(s[:i])
+		unit, ok = units[s[i:]]
+		return n, unit, ok
+	case 2:
+		if tokens[0] == "a" || tokens[0] == "an" {
+			n = 1
+		} else {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimPrefix(tokens[0], "+")); err != nil || n < 0 {
+				return 0, "", false
 			}
-			return &t, nil
 		}
+		unit, ok = units[tokens[1]]
+		return n, unit, ok
 	}
+	return 0, "", false
+}
+
+// parseDayOfMonth reads a day of the month such as "15" or "15th". With
+// ordinal set the suffix is required.
+func parseDayOfMonth(tok string, ordinal bool) (int, bool) {
+	s := tok
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if trimmed, found := strings.CutSuffix(tok, suffix); found {
+			s = trimmed
+			break
+		}
+	}
+	if ordinal && s == tok {
+		return 0, false
+	}
+	day, err := strconv.Atoi(s)
+	if err != nil || len(s) > 2 || day < 1 || day > 31 {
+		return 0, false
+	}
+	return day, true
+}
+
+// nextDayOfMonth returns the next time the month reaches day, today
+// included, skipping months too short to have it.
+func nextDayOfMonth(today time.Time, day int) (time.Time, bool, bool) {
+	first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	for i := 0; i < 12; i++ {
+		month := first.AddDate(0, i, 0)
+		if day > daysIn(month) {
+			continue
+		}
+		if d := month.AddDate(0, 0, day-1); !d.Before(today) {
+			return endOfDay(d), false, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// parseMonthDay reads a calendar date with the month spelled out: "march
+// 3", "3 mar", "3rd of march", each with an optional year. Without one it
+// is the next such date, today included, so February 29 waits for a leap
+// year.
+func parseMonthDay(tokens []string, today time.Time) (time.Time, bool, bool) {
+	var monthTok, dayTok string
+	switch {
+	case len(tokens) >= 2 && isMonth(tokens[0]):
+		monthTok, dayTok, tokens = tokens[0], tokens[1], tokens[2:]
+	case len(tokens) >= 3 && tokens[1] == "of" && isMonth(tokens[2]):
+		dayTok, monthTok, tokens = tokens[0], tokens[2], tokens[3:]
+	case len(tokens) >= 2 && isMonth(tokens[1]):
+		dayTok, monthTok, tokens = tokens[0], tokens[1], tokens[2:]
+	default:
+		return time.Time{}, false, false
+	}
+	day, ok := parseDayOfMonth(dayTok, false)
+	if !ok {
+		return time.Time{}, false, false
+	}
+
+	year, hasYear := today.Year(), false
+	switch len(tokens) {
+	case 0:
+	case 1:
+		y, err := strconv.Atoi(tokens[0])
+		if err != nil || len(tokens[0]) != 4 {
+			return time.Time{}, false, false
+		}
+		year, hasYear = y, true
+	default:
+		return time.Time{}, false, false
+	}
+
+	month := months[monthTok]
+	first := time.Date(year, month, 1, 0, 0, 0, 0, today.Location())
+	// Leap years are at most eight years apart.
+	for i := 0; !hasYear && i < 8 && (day > daysIn(first) || first.AddDate(0, 0, day-1).Before(today)); i++ {
+		first = first.AddDate(1, 0, 0)
+	}
+	if day > daysIn(first) {
+		return time.Time{}, false, false
+	}
+	return endOfDay(first.AddDate(0, 0, day-1)), false, true
+}
+
+func isWeekday(tok string) bool {
+	_, ok := weekdays[tok]
+	return ok
+}
+
+func isMonth(tok string) bool {
+	_, ok := months[tok]
+	return ok
+}
+
+// addMonths moves d by n months, landing on the last day of the month when
+// it is too short, so a month after January 31 is the end of February.
+func addMonths(d time.Time, n int) time.Time {
+	first := time.Date(d.Year(), d.Month()+time.Month(n), 1, 0, 0, 0, 0, d.Location())
+	return first.AddDate(0, 0, min(d.Day(), daysIn(first))-1)
+}
+
+// daysIn returns how many days the month of d has.
+func daysIn(d time.Time) int {
+	return time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func endOfDay(d time.Time) time.Time {
+	return at(d, 23, 59)
+}
 
-	return nil, nil
+func at(d time.Time, hour, minute int) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), hour, minute, 0, 0, d.Location())
 }
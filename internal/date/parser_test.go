@@ -0,0 +1,116 @@
+package date
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// now is a Wednesday morning.
+var now = time.Date(2026, time.October, 14, 10, 30, 0, 0, time.UTC)
+
+func TestParseAt(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  string // "" for no deadline
+	}{
+		{"", ""},
+		{"none", ""},
+		{"2026-11-02T15:00:00Z", "2026-11-02 15:00"},
+
+		{"today", "2026-10-14 23:59"},
+		{"eod", "2026-10-14 23:59"},
+		{"tomorrow", "2026-10-15 23:59"},
+		{"tmr", "2026-10-15 23:59"},
+		{"next week", "2026-10-21 09:00"},
+		{"next month", "2026-11-14 23:59"},
+		{"next year", "2027-10-14 23:59"},
+		{"eow", "2026-10-18 23:59"},
+		{"end of week", "2026-10-18 23:59"},
+		{"end of month", "2026-10-31 23:59"},
+		{"eom", "2026-10-31 23:59"},
+		{"eoy", "2026-12-31 23:59"},
+
+		{"fri", "2026-10-16 23:59"},
+		{"friday", "2026-10-16 23:59"},
+		{"this friday", "2026-10-16 23:59"},
+		{"wed", "2026-10-14 23:59"},
+		{"next friday", "2026-10-23 23:59"},
+		{"next wed", "2026-10-21 23:59"},
+
+		{"in 2 weeks", "2026-10-28 23:59"},
+		{"in a week", "2026-10-21 23:59"},
+		{"in 3 days", "2026-10-17 23:59"},
+		{"+5d", "2026-10-19 23:59"},
+		{"+5 days", "2026-10-19 23:59"},
+		{"in 2 hours", "2026-10-14 12:30"},
+		{"+30min", "2026-10-14 11:00"},
+
+		{"3pm", "2026-10-14 15:00"},
+		{"3:30pm", "2026-10-14 15:30"},
+		{"9am", "2026-10-15 09:00"},
+		{"noon", "2026-10-14 12:00"},
+		{"midnight", "2026-10-15 00:00"},
+		{"3pm tomorrow", "2026-10-15 15:00"},
+		{"tomorrow at 3 pm", "2026-10-15 15:00"},
+		{"mon 14:00", "2026-10-19 14:00"},
+		{"friday at 3pm", "2026-10-16 15:00"},
+
+		{"the 15th", "2026-10-15 23:59"},
+		{"the 14th", "2026-10-14 23:59"},
+		{"13th", "2026-11-13 23:59"},
+		{"march 3", "2027-03-03 23:59"},
+		{"3 mar", "2027-03-03 23:59"},
+		{"3rd of march 2028", "2028-03-03 23:59"},
+		{"oct 14", "2026-10-14 23:59"},
+		{"feb 29", "2028-02-29 23:59"},
+		{"2026-12-01", "2026-12-01 23:59"},
+		{"12/01/2026", "2026-12-01 23:59"},
+	} {
+		d, err := ParseAt(tt.input, now)
+		if err != nil {
+			t.Errorf("ParseAt(%q): %v", tt.input, err)
+			continue
+		}
+		got := ""
+		if d != nil {
+			got = d.Format("2006-01-02 15:04")
+		}
+		if got != tt.want {
+			t.Errorf("ParseAt(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseAtErrors(t *testing.T) {
+	for _, tt := range []struct {
+		input      string
+		reason     string
+		suggestion string // one of the suggestions, or "" for none
+	}{
+		{"fridya", `unknown word "fridya"`, "friday"},
+		{"tomorow 3pm", `unknown word "tomorow"`, "tomorrow 3pm"},
+		{"nxt week", `unknown word "nxt"`, "next week"},
+		{"feb 30", "February has no day 30", ""},
+		{"feb 29 2027", "February 2027 has no day 29", ""},
+		{"3pm 4pm", "more than one time of day", ""},
+		{"in 2 hours 3pm", `"in 2 hours" already sets the time`, ""},
+	} {
+		_, err := ParseAt(tt.input, now)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("ParseAt(%q) = %v, want a ParseError", tt.input, err)
+			continue
+		}
+		if perr.Reason != tt.reason {
+			t.Errorf("ParseAt(%q) reason = %q, want %q", tt.input, perr.Reason, tt.reason)
+		}
+		if tt.suggestion != "" && !slices.Contains(perr.Suggestions, tt.suggestion) {
+			t.Errorf("ParseAt(%q) suggestions = %q, want %q among them", tt.input, perr.Suggestions, tt.suggestion)
+		}
+		if tt.suggestion == "" && len(perr.Suggestions) > 0 {
+			t.Errorf("ParseAt(%q) suggestions = %q, want none", tt.input, perr.Suggestions)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/user/invar/internal/task"
+)
+
+// Exporter writes tasks in some external format.
+type Exporter interface {
+	Export(w io.Writer, tasks []*task.Task) error
+}
+
+// ExporterFunc adapts a function to the Exporter interface.
+type ExporterFunc func(w io.Writer, tasks []*task.Task) error
+
+func (f ExporterFunc) Export(w io.Writer, tasks []*task.Task) error {
+	return f(w, tasks)
+}
+
+var registry = map[string]Exporter{}
+
+// Register makes an exporter available under a format name. Formats in
+// this package register themselves in init.
+func Register(name string, e Exporter) {
+	registry[name] = e
+}
+
+// Get returns the exporter registered under name.
+func Get(name string) (Exporter, error) {
+	e, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q (want %s)", name, strings.Join(Names(), ", "))
+	}
+	return e, nil
+}
+
+// Names returns the registered format names in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// oneLine joins multi-line content for line-based formats.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+func init() {
+	Register("ical", ExporterFunc(exportICal))
+}
+
+// icalPriorities follow RFC 5545: 1 is highest, 9 lowest.
+var icalPriorities = map[task.Priority]string{
+	task.PriorityHigh:   "1",
+	task.PriorityMedium: "5",
+	task.PriorityLow:    "9",
+}
+
+// exportICal writes an iCalendar file with one VTODO per task.
+func exportICal(w io.Writer, tasks []*task.Task) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//invar//invar//EN")
+	for _, t := range tasks {
+		line("BEGIN", "VTODO")
+		line("UID", t.ID+"@invar")
+		line("DTSTAMP", icalTime(t.UpdatedAt))
+		line("CREATED", icalTime(t.CreatedAt))
+		line("LAST-MODIFIED", icalTime(t.UpdatedAt))
+
+		summary, description, _ := strings.Cut(strings.TrimSpace(t.Content), "\n")
+		line("SUMMARY", icalText(summary))
+		if description = strings.TrimSpace(description); description != "" {
+			line("DESCRIPTION", icalText(description))
+		}
+		if t.Deadline != nil {
+			line("DUE", icalTime(*t.Deadline))
+		}
+		line("PRIORITY", icalPriorities[t.Priority])
+		if t.CompletedAt != nil {
+			line("STATUS", "COMPLETED")
+			line("COMPLETED", icalTime(*t.CompletedAt))
+			line("PERCENT-COMPLETE", "100")
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+		if len(t.Tags) > 0 {
+			cats := make([]string, len(t.Tags))
+			for i, tag := range t.Tags {
+				cats[i] = icalText(tag)
+			}
+			line("CATEGORIES", strings.Join(cats, ","))
+		}
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icalText escapes a TEXT value.
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// writeFolded writes a content line with CRLF endings, folding it at 75
+// octets without splitting UTF-8 sequences.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	w.WriteString(s + "\r\n")
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+func init() {
+	Register("todotxt", ExporterFunc(exportTodoTxt))
+	Register("csv", ExporterFunc(exportCSV))
+	Register("markdown", ExporterFunc(exportMarkdown))
+	Register("json", ExporterFunc(exportJSON))
+}
+
+var todoPriorities = map[task.Priority]string{
+	task.PriorityHigh:   "(A) ",
+	task.PriorityMedium: "(B) ",
+	task.PriorityLow:    "(C) ",
+}
+
+// exportTodoTxt writes one todo.txt line per task. Tags become +projects
+// and the deadline becomes due:YYYY-MM-DD. Completed tasks drop their
+// priority, as the todo.txt format asks.
+func exportTodoTxt(w io.Writer, tasks []*task.Task) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		var b strings.Builder
+		if t.CompletedAt != nil {
+			b.WriteString("x " + t.CompletedAt.Format("2006-01-02") + " ")
+		} else {
+			b.WriteString(todoPriorities[t.Priority])
+		}
+		b.WriteString(t.CreatedAt.Format("2006-01-02") + " ")
+		b.WriteString(oneLine(t.Content))
+		for _, tag := range t.Tags {
+			b.WriteString(" +" + strings.ReplaceAll(tag, " ", "_"))
+		}
+		if t.Deadline != nil {
+			b.WriteString(" due:" + t.Deadline.Format("2006-01-02"))
+		}
+		fmt.Fprintln(bw, b.String())
+	}
+	return bw.Flush()
+}
+
+// exportCSV writes a header row and one row per task. The column names
+// match what the CSV importer looks for.
+func exportCSV(w io.Writer, tasks []*task.Task) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "content", "priority", "deadline", "tags", "completed", "created", "updated", "archived"})
+	for _, t := range tasks {
+		cw.Write([]string{
+			t.ID,
+			t.Content,
+			string(t.Priority),
+			formatTime(t.Deadline),
+			strings.Join(t.Tags, ";"),
+			formatTime(t.CompletedAt),
+			t.CreatedAt.Format(time.RFC3339),
+			t.UpdatedAt.Format(time.RFC3339),
+			fmt.Sprint(t.Archived),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportMarkdown writes a GitHub-style checklist.
+func exportMarkdown(w io.Writer, tasks []*task.Task) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		check := " "
+		if t.CompletedAt != nil {
+			check = "x"
+		}
+		lines := strings.Split(strings.TrimSpace(t.Content), "\n")
+		meta := []string{string(t.Priority)}
+		if t.Deadline != nil {
+			meta = append(meta, "due "+t.Deadline.Format("2006-01-02"))
+		}
+		line := fmt.Sprintf("- [%s] %s (%s)", check, lines[0], strings.Join(meta, ", "))
+		for _, tag := range t.Tags {
+			line += " #" + strings.ReplaceAll(tag, " ", "-")
+		}
+		fmt.Fprintln(bw, line)
+		for _, l := range lines[1:] {
+			fmt.Fprintln(bw, "  "+l)
+		}
+	}
+	return bw.Flush()
+}
+
+// exportJSON writes the tasks as a JSON array in the same shape as the
+// task files.
+func exportJSON(w io.Writer, tasks []*task.Task) error {
+	if tasks == nil {
+		tasks = []*task.Task{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tasks)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
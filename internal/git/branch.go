@@ -0,0 +1,222 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+var (
+	// ErrNoCommits is returned when branching off a repo without commits.
+	ErrNoCommits = errors.New("no commits yet")
+	// ErrBranchName is returned for a name git does not allow.
+	ErrBranchName = errors.New("invalid branch name")
+)
+
+// FileDiff is a file that differs between two commits, with its content
+// on both sides. The side the file is missing from is nil.
+type FileDiff struct {
+	Path string
+	Type ChangeType
+	Old  []byte
+	New  []byte
+}
+
+// Branch returns the name of the checked-out branch.
+func (r *Repo) Branch() (string, error) {
+	// HEAD names the branch even before its first commit.
+	head, err := r.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return "", errors.New("HEAD is detached")
+	}
+	return head.Target().Short(), nil
+}
+
+// Branches returns the names of the local branches, sorted.
+func (r *Repo) Branches() ([]string, error) {
+	iter, err := r.repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name().Short())
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// CreateBranch starts a branch at HEAD that tracks upstream, the local
+// branch it is meant to be merged back into.
+func (r *Repo) CreateBranch(name, upstream string) error {
+	ref := plumbing.NewBranchReferenceName(name)
+	if err := ref.Validate(); err != nil {
+		return fmt.Errorf("%w %q", ErrBranchName, name)
+	}
+	if _, err := r.repo.Reference(ref, false); err == nil {
+		return fmt.Errorf("branch %q already exists", name)
+	}
+	head, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrNoCommits
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(ref, head.Hash())); err != nil {
+		return err
+	}
+	return r.repo.CreateBranch(&gitconfig.Branch{
+		Name:   name,
+		Remote: ".",
+		Merge:  plumbing.NewBranchReferenceName(upstream),
+	})
+}
+
+// Upstream returns the local branch name tracks, or "" if it tracks none.
+func (r *Repo) Upstream(name string) (string, error) {
+	b, err := r.repo.Branch(name)
+	if err == git.ErrBranchNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if b.Remote != "." {
+		return "", nil
+	}
+	return b.Merge.Short(), nil
+}
+
+// Checkout switches the worktree to the branch. Local changes must be
+// committed first.
+func (r *Repo) Checkout(name string) error {
+	w, err := r.worktree()
+	if err != nil {
+		return err
+	}
+	return w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name)})
+}
+
+// DeleteBranch removes a branch that is not checked out, along with its
+// settings. Its commits stay in the repo until they are pruned.
+func (r *Repo) DeleteBranch(name string) error {
+	current, err := r.Branch()
+	if err != nil {
+		return err
+	}
+	if name == current {
+		return fmt.Errorf("branch %q is checked out", name)
+	}
+	ref := plumbing.NewBranchReferenceName(name)
+	if _, err := r.repo.Reference(ref, false); err != nil {
+		return fmt.Errorf("no branch %q", name)
+	}
+	if err := r.repo.Storer.RemoveReference(ref); err != nil {
+		return err
+	}
+	if err := r.repo.DeleteBranch(name); err != nil && err != git.ErrBranchNotFound {
+		return err
+	}
+	return nil
+}
+
+// MergeBranch merges the branch into the checked-out one. A branch that is
+// already merged changes nothing and one that is strictly ahead is fast
+// forwarded to; otherwise files changed on both sides go through resolve
+// and the result is a merge commit with the given message. It returns the
+// files that changed.
+func (r *Repo) MergeBranch(name, message string, resolve Resolver) ([]string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	ref, err := r.repo.Reference(plumbing.NewBranchReferenceName(name), true)
+	if err != nil {
+		return nil, fmt.Errorf("no branch %q", name)
+	}
+	ours, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ours.Hash == theirs.Hash, isAncestor(theirs, ours):
+		return nil, nil
+	case isAncestor(ours, theirs):
+		return r.fastForward(head.Name(), ours, theirs)
+	}
+	return r.merge(ours, theirs, message, resolve)
+}
+
+// BranchDiff returns what the branch changed since it split off from base,
+// the way merging it into base would see it.
+func (r *Repo) BranchDiff(name, base string) ([]FileDiff, error) {
+	var tips []*plumbing.Reference
+	for _, b := range []string{base, name} {
+		ref, err := r.repo.Reference(plumbing.NewBranchReferenceName(b), true)
+		if err != nil {
+			return nil, fmt.Errorf("no branch %q", b)
+		}
+		tips = append(tips, ref)
+	}
+	from, err := r.repo.CommitObject(tips[0].Hash())
+	if err != nil {
+		return nil, err
+	}
+	to, err := r.repo.CommitObject(tips[1].Hash())
+	if err != nil {
+		return nil, err
+	}
+	bases, err := from.MergeBase(to)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) > 0 {
+		from = bases[0]
+	}
+
+	changes, err := diffCommits(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []FileDiff
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		d := FileDiff{Path: change.To.Name, Type: ChangeModify}
+		switch action {
+		case merkletrie.Insert:
+			d.Type = ChangeAdd
+		case merkletrie.Delete:
+			d.Path, d.Type = change.From.Name, ChangeDelete
+		}
+		oldFile, newFile, err := change.Files()
+		if err != nil {
+			return nil, err
+		}
+		if d.Old, err = fileContent(oldFile); err != nil {
+			return nil, err
+		}
+		if d.New, err = fileContent(newFile); err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
@@ -0,0 +1,151 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+)
+
+const bundleHeader = "# v2 git bundle"
+
+// WriteBundle writes the repository as a git bundle (v2), which plain git
+// can clone or fetch from. It contains HEAD, every branch and all objects.
+func (r *Repo) WriteBundle(w io.Writer) error {
+	head, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return errors.New("repository has no commits")
+	}
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, bundleHeader)
+	fmt.Fprintf(bw, "%s HEAD\n", head.Hash())
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && ref.Name().IsBranch() {
+			fmt.Fprintf(bw, "%s %s\n", ref.Hash(), ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(bw)
+
+	var hashes []plumbing.Hash
+	iter, err := r.repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return err
+	}
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		hashes = append(hashes, obj.Hash())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := packfile.NewEncoder(bw, r.repo.Storer, false).Encode(hashes, 10); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// InitFromBundle creates a repository at path from a bundle written by
+// WriteBundle. HEAD points at the bundled branch it matched. The worktree
+// files are left alone; the index is reset to HEAD so files restored
+// alongside show up as unchanged.
+func InitFromBundle(path string, bundle io.Reader) (*Repo, error) {
+	br := bufio.NewReader(bundle)
+	header, err := br.ReadString('\n')
+	if err != nil || strings.TrimSpace(header) != bundleHeader {
+		return nil, errors.New("not a v2 git bundle")
+	}
+
+	var head plumbing.Hash
+	var branches []*plumbing.Reference
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle refs: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("unsupported bundle line %q", line)
+		}
+		if name == "HEAD" {
+			head = plumbing.NewHash(hash)
+			continue
+		}
+		branches = append(branches, plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(hash)))
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := packfile.UpdateObjectStorage(repo.Storer, br); err != nil {
+		return nil, fmt.Errorf("reading bundle pack: %w", err)
+	}
+
+	var headBranch plumbing.ReferenceName
+	for _, ref := range branches {
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+		if ref.Hash() == head && (headBranch == "" || ref.Name() == plumbing.Master || ref.Name() == plumbing.Main) {
+			headBranch = ref.Name()
+		}
+	}
+	if headBranch == "" {
+		headBranch = plumbing.Master
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(headBranch, head)); err != nil {
+			return nil, err
+		}
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, headBranch)); err != nil {
+		return nil, err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: head, Mode: git.MixedReset}); err != nil {
+		return nil, err
+	}
+	return &Repo{path: path, repo: repo}, nil
+}
+
+// Head returns the hash of the commit HEAD points at, or "" for an empty
+// repository.
+func (r *Repo) Head() (string, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
@@ -0,0 +1,177 @@
+package git
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// hashPattern finds full commit hashes in commit messages, such as the ones
+// in undo and restore trailers.
+var hashPattern = regexp.MustCompile(`\b[0-9a-f]{40}\b`)
+
+// Squash rewrites the first-parent history of HEAD so that the commits
+// made before the given time are replaced by one commit per period, where
+// bucket names the period a commit falls in. Newer commits are replayed on
+// top with their trees, authors and messages, but merges become plain
+// commits. Hashes of rewritten commits mentioned in messages are updated.
+// It returns how many commits were squashed into how many.
+func (r *Repo) Squash(before time.Time, bucket func(time.Time) string) (int, int, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	head, err := r.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var chain []*object.Commit
+	commit, err := r.repo.CommitObject(ref.Hash())
+	for err == nil {
+		chain = append(chain, commit)
+		if commit.NumParents() == 0 {
+			break
+		}
+		commit, err = commit.Parent(0)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	old := 0
+	for old < len(chain) && chain[old].Author.When.Before(before) {
+		old++
+	}
+	var groups [][]*object.Commit
+	for _, c := range chain[:old] {
+		key := bucket(c.Author.When)
+		if n := len(groups); n > 0 && bucket(groups[n-1][0].Author.When) == key {
+			groups[n-1] = append(groups[n-1], c)
+		} else {
+			groups = append(groups, []*object.Commit{c})
+		}
+	}
+	if len(groups) == old {
+		return 0, 0, nil
+	}
+
+	rewritten := map[string]string{}
+	var parent plumbing.Hash
+	for _, group := range groups {
+		last := group[len(group)-1]
+		message := fmt.Sprintf("Squash %d commits from %s\n", len(group), bucket(last.Author.When))
+		if len(group) == 1 {
+			message = last.Message
+		}
+		hash, err := r.writeCommit(last, message, parent)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, c := range group {
+			rewritten[c.Hash.String()] = hash.String()
+		}
+		parent = hash
+	}
+	for _, c := range chain[old:] {
+		message := hashPattern.ReplaceAllStringFunc(c.Message, func(h string) string {
+			if n, ok := rewritten[h]; ok {
+				return n
+			}
+			return h
+		})
+		hash, err := r.writeCommit(c, message, parent)
+		if err != nil {
+			return 0, 0, err
+		}
+		rewritten[c.Hash.String()] = hash.String()
+		parent = hash
+	}
+
+	name := ref.Name()
+	if head.Type() == plumbing.SymbolicReference {
+		name = head.Target()
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(name, parent)); err != nil {
+		return 0, 0, err
+	}
+	return old, len(groups), nil
+}
+
+// Repack deletes the objects no ref can reach and packs the rest into a
+// single packfile. A repo without commits is left alone.
+func (r *Repo) Repack() error {
+	if _, err := r.repo.Head(); err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err := r.repo.Prune(git.PruneOptions{Handler: r.repo.DeleteObject}); err != nil {
+		return err
+	}
+	// Reflogs are only written by plain git, and after the prune they can
+	// point at objects that no longer exist.
+	if err := os.RemoveAll(filepath.Join(r.path, ".git", "logs")); err != nil {
+		return err
+	}
+	if err := r.repo.RepackObjects(&git.RepackConfig{}); err != nil {
+		return err
+	}
+	// Every loose object left is reachable and now also in the pack.
+	if los, ok := r.repo.Storer.(storer.LooseObjectStorer); ok {
+		var loose []plumbing.Hash
+		err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+			loose = append(loose, h)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, h := range loose {
+			if err := los.DeleteLooseObject(h); err != nil {
+				return err
+			}
+		}
+	}
+	// The storage keeps the packs it has opened, which are gone now.
+	return r.Reopen()
+}
+
+// Size returns the disk space used by the repo's objects.
+func (r *Repo) Size() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(filepath.Join(r.path, ".git", "objects"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Day and Week name the period a time falls in, for Squash.
+func Day(t time.Time) string {
+	return t.Local().Format("2006-01-02")
+}
+
+func Week(t time.Time) string {
+	year, week := t.Local().ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
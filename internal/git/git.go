@@ -1,18 +1,30 @@
 package git
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
 )
 
 type Repo struct {
-	path string
-	repo *git.Repository
+	path   string
+	repo   *git.Repository
+	signer Signer
 }
 
 func Init(path string) (*Repo, error) {
@@ -39,7 +51,7 @@ func Init(path string) (*Repo, error) {
 }
 
 func (r *Repo) Commit(message string) error {
-	w, err := r.repo.Worktree()
+	w, err := r.worktree()
 	if err != nil {
 		return err
 	}
@@ -58,35 +70,570 @@ func (r *Repo) Commit(message string) error {
 		return nil
 	}
 
-	_, err = w.Commit(message, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "Invar",
-			Email: "invar@localhost",
-			When:  time.Now(),
-		},
+	_, err = w.Commit(message, r.commitOptions())
+	return err
+}
+
+// unstagedFile lists, one per line, the paths CommitPaths committed
+// without updating the index. The index is brought up to date before the
+// worktree is next used, or by SyncIndex.
+const unstagedFile = "invar-unstaged"
+
+// CommitPaths records the given files, and nothing else, as a new commit.
+// Paths may be absolute or relative to the repo; ones that no longer exist
+// are removed. It builds the new tree from HEAD's, rewriting only the trees
+// on the way to the changed files, and leaves the index alone, so its cost
+// barely grows with the number of files. It is a no-op when none of the
+// paths changed.
+func (r *Repo) CommitPaths(message string, paths ...string) error {
+	changes := map[string]*object.TreeEntry{}
+	for _, p := range paths {
+		if filepath.IsAbs(p) {
+			var err error
+			if p, err = filepath.Rel(r.path, p); err != nil {
+				return err
+			}
+		}
+		p = filepath.ToSlash(p)
+		e, err := r.blob(p)
+		if err != nil {
+			return err
+		}
+		changes[p] = e
+	}
+
+	branch, parent, err := r.headBranch()
+	if err != nil {
+		return err
+	}
+	var base plumbing.Hash
+	if !parent.IsZero() {
+		c, err := r.repo.CommitObject(parent)
+		if err != nil {
+			return err
+		}
+		base = c.TreeHash
+	}
+	tree, err := r.buildTree(base, changes)
+	if err != nil {
+		return err
+	}
+	if tree == base && !parent.IsZero() {
+		return nil
+	}
+	if tree.IsZero() {
+		if tree, err = r.writeTree(&object.Tree{}); err != nil {
+			return err
+		}
+	}
+
+	author := signature()
+	hash, err := r.writeCommit(&object.Commit{Author: *author, Committer: *author, TreeHash: tree}, message, parent)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(branch, hash)); err != nil {
+		return err
+	}
+	return r.markUnstaged(slices.Sorted(maps.Keys(changes)))
+}
+
+// writeCommit stores a copy of c with the given message and parent, signed
+// if a signer is set, and returns its hash.
+func (r *Repo) writeCommit(c *object.Commit, message string, parent plumbing.Hash) (plumbing.Hash, error) {
+	n := &object.Commit{
+		Author:    c.Author,
+		Committer: c.Committer,
+		Message:   message,
+		TreeHash:  c.TreeHash,
+	}
+	if !parent.IsZero() {
+		n.ParentHashes = []plumbing.Hash{parent}
+	}
+	if r.signer != nil {
+		unsigned := &plumbing.MemoryObject{}
+		if err := n.EncodeWithoutSignature(unsigned); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		reader, err := unsigned.Reader()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		sig, err := r.signer.Sign(reader)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		n.PGPSignature = string(sig)
+	}
+
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := n.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+// Reopen drops what the repo has cached about its storage, such as the
+// packs it has opened, to see what another process or repack wrote.
+func (r *Repo) Reopen() error {
+	repo, err := git.PlainOpen(r.path)
+	if err != nil {
+		return err
+	}
+	r.repo = repo
+	return nil
+}
+
+// headBranch returns the branch HEAD points at and the commit at its tip,
+// which is zero before the first commit.
+func (r *Repo) headBranch() (plumbing.ReferenceName, plumbing.Hash, error) {
+	head, err := r.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+	name := head.Name()
+	if head.Type() == plumbing.SymbolicReference {
+		name = head.Target()
+	}
+	ref, err := r.repo.Reference(name, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return name, plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+	return name, ref.Hash(), nil
+}
+
+// blob stores the worktree file at p and returns its tree entry, or nil if
+// the file is gone.
+func (r *Repo) blob(p string) (*object.TreeEntry, error) {
+	full := filepath.Join(r.path, filepath.FromSlash(p))
+	info, err := os.Lstat(full)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return nil, err
+	}
+
+	obj := r.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(data)))
+	w, err := obj.Writer()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	hash, err := r.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &object.TreeEntry{Name: path.Base(p), Mode: mode, Hash: hash}, nil
+}
+
+// buildTree applies changes, keyed by path relative to the tree, to the
+// tree with the given hash, which may be zero for an empty one. A nil entry
+// removes the file. Only the trees along the changed paths are rewritten.
+// It returns the new tree's hash, or zero if the tree ends up empty.
+func (r *Repo) buildTree(base plumbing.Hash, changes map[string]*object.TreeEntry) (plumbing.Hash, error) {
+	entries := map[string]object.TreeEntry{}
+	if !base.IsZero() {
+		tree, err := r.repo.TreeObject(base)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		for _, e := range tree.Entries {
+			entries[e.Name] = e
+		}
+	}
+
+	subdirs := map[string]map[string]*object.TreeEntry{}
+	for p, e := range changes {
+		dir, rest, nested := strings.Cut(p, "/")
+		if nested {
+			if subdirs[dir] == nil {
+				subdirs[dir] = map[string]*object.TreeEntry{}
+			}
+			subdirs[dir][rest] = e
+		} else if e == nil {
+			delete(entries, p)
+		} else {
+			entries[p] = *e
+		}
+	}
+	for dir, sub := range subdirs {
+		var subBase plumbing.Hash
+		if e, ok := entries[dir]; ok && e.Mode == filemode.Dir {
+			subBase = e.Hash
+		}
+		hash, err := r.buildTree(subBase, sub)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if hash.IsZero() {
+			delete(entries, dir)
+		} else {
+			entries[dir] = object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash}
+		}
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+	tree := &object.Tree{}
+	for _, e := range entries {
+		tree.Entries = append(tree.Entries, e)
+	}
+	// Git orders entries as if directory names ended in a slash.
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortKey(tree.Entries[i]) < sortKey(tree.Entries[j])
 	})
+	hash, err := r.writeTree(tree)
+	if err != nil || hash != base {
+		return hash, err
+	}
+	return base, nil
+}
+
+func (r *Repo) writeTree(t *object.Tree) (plumbing.Hash, error) {
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := t.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.repo.Storer.SetEncodedObject(obj)
+}
+
+// markUnstaged records paths committed behind the index's back.
+func (r *Repo) markUnstaged(paths []string) error {
+	f, err := os.OpenFile(filepath.Join(r.path, ".git", unstagedFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strings.Join(paths, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 	return err
 }
 
-func (r *Repo) Log() ([]string, error) {
-	ref, err := r.repo.Head()
+// SyncIndex brings the index up to date with the files CommitPaths
+// committed, so that plain git sees a clean worktree. Programs using the
+// repo should call it before they exit.
+func (r *Repo) SyncIndex() error {
+	marker := filepath.Join(r.path, ".git", unstagedFile)
+	data, err := os.ReadFile(marker)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, p := range strings.Split(string(data), "\n") {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if err := r.stage(idx, p); err != nil {
+			return err
+		}
+	}
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		return err
+	}
+	// Another process may have committed meanwhile; keep what it added.
+	now, err := os.ReadFile(marker)
+	if err != nil || len(now) <= len(data) {
+		return os.Remove(marker)
+	}
+	return os.WriteFile(marker, now[len(data):], 0644)
+}
+
+// worktree returns the repo's worktree with the index brought up to date
+// first. Everything that uses the index goes through it.
+func (r *Repo) worktree() (*git.Worktree, error) {
+	if err := r.SyncIndex(); err != nil {
+		return nil, err
+	}
+	return r.repo.Worktree()
+}
+
+// stage updates the index entry for p to the file in the worktree, or
+// removes it if the file is gone.
+func (r *Repo) stage(idx *index.Index, p string) error {
+	blob, err := r.blob(p)
+	if err != nil {
+		return err
+	}
+	if blob == nil {
+		if _, err := idx.Remove(p); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+			return err
+		}
+		return nil
+	}
+	info, err := os.Lstat(filepath.Join(r.path, filepath.FromSlash(p)))
+	if err != nil {
+		return err
+	}
+	e, err := idx.Entry(p)
+	if err != nil {
+		e = idx.Add(p)
+	}
+	e.Hash = blob.Hash
+	e.Mode = blob.Mode
+	e.ModifiedAt = info.ModTime()
+	e.Size = uint32(info.Size())
+	return nil
+}
+
+// Dirty returns the paths in the worktree with uncommitted changes. Given
+// paths, it only compares those with HEAD, which is much faster than a full
+// status of a large data dir.
+func (r *Repo) Dirty(paths ...string) ([]string, error) {
+	if len(paths) > 0 {
+		return r.dirtyPaths(paths)
+	}
+	w, err := r.worktree()
 	if err != nil {
 		return nil, err
 	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	var dirty []string
+	for path, st := range status {
+		if st.Staging != git.Unmodified || st.Worktree != git.Unmodified {
+			dirty = append(dirty, path)
+		}
+	}
+	sort.Strings(dirty)
+	return dirty, nil
+}
 
-	iter, err := r.repo.Log(&git.LogOptions{From: ref.Hash()})
+func (r *Repo) dirtyPaths(paths []string) ([]string, error) {
+	_, head, err := r.headBranch()
 	if err != nil {
 		return nil, err
 	}
+	var tree *object.Tree
+	if !head.IsZero() {
+		c, err := r.repo.CommitObject(head)
+		if err != nil {
+			return nil, err
+		}
+		if tree, err = c.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	var dirty []string
+	for _, p := range paths {
+		var hash plumbing.Hash
+		data, err := os.ReadFile(filepath.Join(r.path, filepath.FromSlash(p)))
+		if err == nil {
+			hash = plumbing.ComputeHash(plumbing.BlobObject, data)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if hash != entryHash(tree, p) {
+			dirty = append(dirty, p)
+		}
+	}
+	sort.Strings(dirty)
+	return dirty, nil
+}
+
+// Verify walks every commit reachable from HEAD and reads its tree, so
+// missing or corrupt objects are reported instead of silently ending the
+// history early. An empty repository is valid.
+func (r *Repo) Verify() error {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading HEAD: %w", err)
+	}
+
+	iter, err := r.repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return err
+	}
 	defer iter.Close()
 
-	var logs []string
 	for {
 		commit, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading history: %w", err)
+		}
+		if _, err := commit.Tree(); err != nil {
+			return fmt.Errorf("commit %s: %w", commit.Hash.String()[:7], err)
+		}
+	}
+}
+
+// Removal is a file deleted by a commit, with its content just before the
+// deletion.
+type Removal struct {
+	Path    string
+	Content []byte
+	Hash    string
+	When    time.Time
+}
+
+// Removals returns every file deletion in the first-parent history of
+// HEAD, newest first.
+func (r *Repo) Removals() ([]Removal, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var removals []Removal
+	commit, err := r.repo.CommitObject(ref.Hash())
+	for err == nil && commit.NumParents() > 0 {
+		var parent *object.Commit
+		parent, err = commit.Parent(0)
 		if err != nil {
 			break
 		}
-		logs = append(logs, fmt.Sprintf("%s %s %s", commit.Hash.String()[:7], commit.Author.When.Format("2006-01-02"), commit.Message))
+		var changes object.Changes
+		changes, err = diffCommits(parent, commit)
+		if err != nil {
+			break
+		}
+		for _, change := range changes {
+			action, aerr := change.Action()
+			if aerr != nil || action != merkletrie.Delete {
+				continue
+			}
+			from, _, ferr := change.Files()
+			if ferr != nil {
+				return nil, ferr
+			}
+			content, cerr := from.Contents()
+			if cerr != nil {
+				return nil, cerr
+			}
+			removals = append(removals, Removal{
+				Path:    change.From.Name,
+				Content: []byte(content),
+				Hash:    commit.Hash.String(),
+				When:    commit.Author.When,
+			})
+		}
+		commit = parent
+	}
+	if err != nil {
+		return nil, err
+	}
+	return removals, nil
+}
+
+// FindCommit walks the history of HEAD, newest first, and returns the hash
+// and message of the first commit for which match returns true. It returns
+// empty strings if no commit matches.
+func (r *Repo) FindCommit(match func(hash, message string) bool) (string, string, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	iter, err := r.repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return "", "", err
+	}
+	defer iter.Close()
+
+	for {
+		commit, err := iter.Next()
+		if err == io.EOF {
+			return "", "", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if match(commit.Hash.String(), commit.Message) {
+			return commit.Hash.String(), commit.Message, nil
+		}
+	}
+}
+
+// AddedFiles returns the files a commit added relative to its first
+// parent, mapping each path to the hash of the content it was added with.
+func (r *Repo) AddedFiles(hash string) (map[string]string, error) {
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	files, err := fileChanges(commit)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	added := map[string]string{}
+	for _, f := range files {
+		if f.Type != ChangeAdd {
+			continue
+		}
+		entry, err := tree.FindEntry(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		added[f.Path] = entry.Hash.String()
+	}
+	return added, nil
+}
+
+// HashBlob returns the hash git gives a file with the given content, to
+// compare with the ones AddedFiles returns.
+func HashBlob(data []byte) string {
+	return plumbing.ComputeHash(plumbing.BlobObject, data).String()
+}
+
+func diffCommits(from, to *object.Commit) (object.Changes, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
 	}
-	return logs, nil
+	return object.DiffTree(fromTree, toTree)
 }
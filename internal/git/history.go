@@ -0,0 +1,99 @@
+package git
+
+import (
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Version is a commit that changed one of the files asked for. Content is
+// nil when the commit removed the file.
+type Version struct {
+	Hash    string
+	When    time.Time
+	Message string
+	Path    string
+	Content []byte
+}
+
+// FileHistory returns every commit on the first-parent line of HEAD that
+// added, changed or removed one of paths, newest first. A commit that moves
+// a file from one of the paths to another yields only the new one.
+func (r *Repo) FileHistory(paths []string) ([]Version, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for commit != nil {
+		var parent *object.Commit
+		if commit.NumParents() > 0 {
+			if parent, err = commit.Parent(0); err != nil {
+				return nil, err
+			}
+		}
+		now, err := blobs(commit, paths)
+		if err != nil {
+			return nil, err
+		}
+		before, err := blobs(parent, paths)
+		if err != nil {
+			return nil, err
+		}
+
+		var added, removed []Version
+		for _, p := range paths {
+			if fileHash(now[p]) == fileHash(before[p]) {
+				continue
+			}
+			v := Version{Hash: commit.Hash.String(), When: commit.Author.When, Message: commit.Message, Path: p}
+			if now[p] == nil {
+				removed = append(removed, v)
+				continue
+			}
+			if v.Content, err = fileContent(now[p]); err != nil {
+				return nil, err
+			}
+			added = append(added, v)
+		}
+		if len(added) > 0 {
+			versions = append(versions, added...)
+		} else {
+			versions = append(versions, removed...)
+		}
+		commit = parent
+	}
+	return versions, nil
+}
+
+// blobs looks up paths in a commit's tree. Missing paths map to nil.
+func blobs(c *object.Commit, paths []string) (map[string]*object.File, error) {
+	found := map[string]*object.File{}
+	if c == nil {
+		return found, nil
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		f, err := tree.File(p)
+		if err == object.ErrFileNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found[p] = f
+	}
+	return found, nil
+}
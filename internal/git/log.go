@@ -0,0 +1,233 @@
+package git
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// ChangeType says what a commit did to a file.
+type ChangeType string
+
+const (
+	ChangeAdd    ChangeType = "add"
+	ChangeModify ChangeType = "modify"
+	ChangeDelete ChangeType = "delete"
+)
+
+// FileChange is a file a commit added, modified or deleted.
+type FileChange struct {
+	Path string     `json:"path"`
+	Type ChangeType `json:"type"`
+}
+
+// Commit is a commit as seen by invar. Files is only filled in by Log and
+// lists the changes against the first parent.
+type Commit struct {
+	Hash    string       `json:"hash"`
+	Author  string       `json:"author"`
+	Email   string       `json:"email"`
+	When    time.Time    `json:"time"`
+	Message string       `json:"message"`
+	Parents int          `json:"parents"`
+	Files   []FileChange `json:"files,omitempty"`
+}
+
+// Subject returns the first line of the commit message.
+func (c Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+func newCommit(c *object.Commit) Commit {
+	return Commit{
+		Hash:    c.Hash.String(),
+		Author:  c.Author.Name,
+		Email:   c.Author.Email,
+		When:    c.Author.When,
+		Message: c.Message,
+		Parents: c.NumParents(),
+	}
+}
+
+// LogOptions selects the commits Log returns. Zero fields match every
+// commit. Paths match a file or anything below a dir; Author matches part
+// of the author's name or email. Skip and Limit page through the commits
+// left after filtering.
+type LogOptions struct {
+	Paths  []string
+	Since  time.Time
+	Until  time.Time
+	Author string
+	Skip   int
+	Limit  int
+}
+
+// Log returns the commits reachable from HEAD, newest first, with the files
+// each one changed.
+func (r *Repo) Log(opts LogOptions) ([]Commit, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.repo.Log(&git.LogOptions{From: ref.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []Commit
+	skip := opts.Skip
+	err = iter.ForEach(func(c *object.Commit) error {
+		// Commits come newest committed first, and none is authored after
+		// it was committed, so the rest are all too old.
+		if !opts.Since.IsZero() && c.Committer.When.Before(opts.Since) {
+			return storer.ErrStop
+		}
+		if !opts.Since.IsZero() && c.Author.When.Before(opts.Since) {
+			return nil
+		}
+		if !opts.Until.IsZero() && !c.Author.When.Before(opts.Until) {
+			return nil
+		}
+		if opts.Author != "" && !strings.Contains(strings.ToLower(c.Author.Name+" <"+c.Author.Email+">"), strings.ToLower(opts.Author)) {
+			return nil
+		}
+		if len(opts.Paths) > 0 {
+			ok, err := changesPaths(c, opts.Paths)
+			if err != nil || !ok {
+				return err
+			}
+		}
+		if skip > 0 {
+			skip--
+			return nil
+		}
+
+		files, err := fileChanges(c)
+		if err != nil {
+			return err
+		}
+		commit := newCommit(c)
+		commit.Files = files
+		commits = append(commits, commit)
+		if opts.Limit > 0 && len(commits) == opts.Limit {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// changesPaths reports whether c changed any of paths, or anything below
+// one of them, against its first parent. It compares the entries for the
+// paths alone rather than diffing the whole trees.
+func changesPaths(c *object.Commit, paths []string) (bool, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return false, err
+	}
+	var parent *object.Tree
+	if c.NumParents() > 0 {
+		p, err := c.Parent(0)
+		if err != nil {
+			return false, err
+		}
+		if parent, err = p.Tree(); err != nil {
+			return false, err
+		}
+	}
+	for _, p := range paths {
+		p = strings.TrimSuffix(p, "/")
+		if entryHash(tree, p) != entryHash(parent, p) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// entryHash returns the hash of the file or dir at p in tree, or the zero
+// hash if there is none.
+func entryHash(tree *object.Tree, p string) plumbing.Hash {
+	if tree == nil {
+		return plumbing.ZeroHash
+	}
+	entry, err := tree.FindEntry(p)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+// fileChanges returns the files c changed against its first parent, or
+// every file for a root commit.
+func fileChanges(c *object.Commit) ([]FileChange, error) {
+	var changes object.Changes
+	if c.NumParents() == 0 {
+		tree, err := c.Tree()
+		if err != nil {
+			return nil, err
+		}
+		if changes, err = object.DiffTree(nil, tree); err != nil {
+			return nil, err
+		}
+	} else {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if changes, err = diffCommits(parent, c); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []FileChange
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case merkletrie.Insert:
+			files = append(files, FileChange{Path: change.To.Name, Type: ChangeAdd})
+		case merkletrie.Delete:
+			files = append(files, FileChange{Path: change.From.Name, Type: ChangeDelete})
+		default:
+			files = append(files, FileChange{Path: change.To.Name, Type: ChangeModify})
+		}
+	}
+	return files, nil
+}
+
+// Walk calls fn for HEAD and its first parents, newest first, until fn
+// returns false or the root commit is reached.
+func (r *Repo) Walk(fn func(Commit) bool) error {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	commit, err := r.repo.CommitObject(ref.Hash())
+	for err == nil {
+		c := newCommit(commit)
+		if !fn(c) || c.Parents == 0 {
+			return nil
+		}
+		commit, err = commit.Parent(0)
+	}
+	return err
+}
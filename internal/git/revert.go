@@ -0,0 +1,46 @@
+package git
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Revert returns what each file the given commit changed held before it,
+// nil for the files it added, for the caller to write back. The worktree is
+// left alone.
+func (r *Repo) Revert(hash string) (map[string][]byte, error) {
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	var parent *object.Commit
+	if commit.NumParents() > 0 {
+		if parent, err = commit.Parent(0); err != nil {
+			return nil, err
+		}
+	}
+	after, err := treeFiles(commit)
+	if err != nil {
+		return nil, err
+	}
+	before, err := treeFiles(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for p := range after {
+		if before[p] == nil {
+			files[p] = nil
+		}
+	}
+	for p, f := range before {
+		if fileHash(after[p]) == f.Hash {
+			continue
+		}
+		if files[p], err = fileContent(f); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
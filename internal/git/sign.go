@@ -0,0 +1,409 @@
+package git
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// Signature formats for commit signing.
+const (
+	FormatOpenPGP = "openpgp"
+	FormatSSH     = "ssh"
+)
+
+// sshNamespace is the namespace git uses for SSH commit signatures.
+const sshNamespace = "git"
+
+const (
+	sshArmorStart = "-----BEGIN SSH SIGNATURE-----"
+	sshArmorEnd   = "-----END SSH SIGNATURE-----"
+)
+
+// Signer signs commits. Commits it signs count as trusted by the keyring it
+// is added to, without listing its public key separately.
+type Signer interface {
+	Sign(message io.Reader) ([]byte, error)
+	trust(k *Keyring)
+}
+
+// NewSigner reads a private key in the given format: an armored OpenPGP
+// secret key, or an OpenSSH private key. The passphrase is only needed for
+// protected keys.
+func NewSigner(format string, key, passphrase []byte) (Signer, error) {
+	switch format {
+	case FormatOpenPGP:
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("reading OpenPGP key: %w", err)
+		}
+		for _, e := range entities {
+			if e.PrivateKey == nil {
+				continue
+			}
+			if e.PrivateKey.Encrypted {
+				if len(passphrase) == 0 {
+					return nil, errors.New("the signing key is protected by a passphrase")
+				}
+				if err := e.DecryptPrivateKeys(passphrase); err != nil {
+					return nil, fmt.Errorf("unlocking OpenPGP key: %w", err)
+				}
+			}
+			return pgpSigner{e}, nil
+		}
+		return nil, errors.New("no OpenPGP secret key found")
+	case FormatSSH:
+		var s ssh.Signer
+		var err error
+		if len(passphrase) > 0 {
+			s, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		} else {
+			s, err = ssh.ParsePrivateKey(key)
+		}
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, errors.New("the signing key is protected by a passphrase")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading SSH key: %w", err)
+		}
+		return sshSigner{s}, nil
+	}
+	return nil, fmt.Errorf("unknown signing format %q", format)
+}
+
+// SetSigner makes Commit sign new commits with s. A nil s turns signing off.
+func (r *Repo) SetSigner(s Signer) {
+	r.signer = s
+}
+
+// commitOptions returns the options for a new commit, signed if a signer
+// is set.
+func (r *Repo) commitOptions() *git.CommitOptions {
+	opts := &git.CommitOptions{Author: signature()}
+	if r.signer != nil {
+		opts.Signer = r.signer
+	}
+	return opts
+}
+
+type pgpSigner struct {
+	entity *openpgp.Entity
+}
+
+func (s pgpSigner) Sign(message io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, s.entity, message, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s pgpSigner) trust(k *Keyring) {
+	k.pgp = append(k.pgp, s.entity)
+}
+
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+// sshSignedData is what an SSH signature covers, after the magic preamble.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// sshSignature is the SSHSIG blob, after the magic preamble.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+func (s sshSigner) Sign(message io.Reader) ([]byte, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+	data := append([]byte("SSHSIG"), ssh.Marshal(sshSignedData{
+		Namespace:     sshNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          h.Sum(nil),
+	})...)
+
+	var sig *ssh.Signature
+	var err error
+	if as, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = as.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = s.signer.Sign(rand.Reader, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(sshSignature{
+		Version:       1,
+		PublicKey:     s.signer.PublicKey().Marshal(),
+		Namespace:     sshNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	var buf bytes.Buffer
+	buf.WriteString(sshArmorStart + "\n")
+	for len(encoded) > 70 {
+		buf.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	buf.WriteString(encoded + "\n" + sshArmorEnd + "\n")
+	return buf.Bytes(), nil
+}
+
+func (s sshSigner) trust(k *Keyring) {
+	k.ssh = append(k.ssh, s.signer.PublicKey())
+}
+
+// Keyring holds the public keys whose signatures count as trusted.
+type Keyring struct {
+	pgp openpgp.EntityList
+	ssh []ssh.PublicKey
+}
+
+// NewKeyring reads trusted public keys. Each file is either an armored
+// OpenPGP key ring or SSH public keys, one per line in authorized_keys or
+// allowed_signers form.
+func NewKeyring(files ...[]byte) (*Keyring, error) {
+	k := &Keyring{}
+	for _, data := range files {
+		if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+			entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("reading OpenPGP keys: %w", err)
+			}
+			k.pgp = append(k.pgp, entities...)
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+			if err != nil {
+				// allowed_signers lines start with the principal.
+				if _, rest, ok := strings.Cut(line, " "); ok {
+					key, _, _, _, err = ssh.ParseAuthorizedKey([]byte(rest))
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading SSH key %q: %w", line, err)
+			}
+			k.ssh = append(k.ssh, key)
+		}
+	}
+	return k, nil
+}
+
+// Trust adds the public key of s to the keyring.
+func (k *Keyring) Trust(s Signer) {
+	s.trust(k)
+}
+
+// SignatureStatus is the outcome of checking one commit's signature.
+type SignatureStatus int
+
+const (
+	SignatureGood SignatureStatus = iota
+	SignatureUnsigned
+	SignatureUntrusted
+	SignatureBad
+)
+
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureGood:
+		return "good"
+	case SignatureUnsigned:
+		return "unsigned"
+	case SignatureUntrusted:
+		return "untrusted"
+	}
+	return "bad"
+}
+
+// SignatureCheck is the signature status of one commit. Signer describes
+// the key that made a good or untrusted signature.
+type SignatureCheck struct {
+	Hash    string
+	Subject string
+	When    time.Time
+	Status  SignatureStatus
+	Signer  string
+}
+
+// CheckSignatures checks the signature of every commit reachable from HEAD
+// against the keyring, newest first. Commits reachable from since are
+// skipped, so only the ones added after it are checked.
+func (r *Repo) CheckSignatures(k *Keyring, since string) ([]SignatureCheck, error) {
+	ref, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[plumbing.Hash]bool{}
+	if since != "" {
+		if err := r.ancestors(plumbing.NewHash(since), seen); err != nil {
+			return nil, err
+		}
+	}
+
+	iter, err := r.repo.Log(&git.LogOptions{From: ref.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var checks []SignatureCheck
+	err = iter.ForEach(func(c *object.Commit) error {
+		if seen[c.Hash] {
+			return nil
+		}
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		check := SignatureCheck{Hash: c.Hash.String(), Subject: subject, When: c.Author.When}
+		check.Status, check.Signer = k.verify(c)
+		checks = append(checks, check)
+		return nil
+	})
+	return checks, err
+}
+
+// ancestors adds hash and every commit reachable from it to seen.
+func (r *Repo) ancestors(hash plumbing.Hash, seen map[plumbing.Hash]bool) error {
+	iter, err := r.repo.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	return iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+}
+
+// verify checks the signature on c against the keyring.
+func (k *Keyring) verify(c *object.Commit) (SignatureStatus, string) {
+	if c.PGPSignature == "" {
+		return SignatureUnsigned, ""
+	}
+	encoded := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(encoded); err != nil {
+		return SignatureBad, ""
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return SignatureBad, ""
+	}
+	message, err := io.ReadAll(reader)
+	if err != nil {
+		return SignatureBad, ""
+	}
+
+	if strings.HasPrefix(c.PGPSignature, sshArmorStart) {
+		return k.verifySSH(message, c.PGPSignature)
+	}
+	return k.verifyPGP(message, c.PGPSignature)
+}
+
+func (k *Keyring) verifyPGP(message []byte, signature string) (SignatureStatus, string) {
+	entity, err := openpgp.CheckArmoredDetachedSignature(k.pgp, bytes.NewReader(message), strings.NewReader(signature), nil)
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return SignatureUntrusted, "unknown OpenPGP key"
+	}
+	if err != nil {
+		return SignatureBad, ""
+	}
+	if id := entity.PrimaryIdentity(); id != nil {
+		return SignatureGood, id.Name
+	}
+	return SignatureGood, fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
+
+func (k *Keyring) verifySSH(message []byte, signature string) (SignatureStatus, string) {
+	body := strings.TrimSpace(signature)
+	body = strings.TrimPrefix(body, sshArmorStart)
+	body = strings.TrimSuffix(body, sshArmorEnd)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil || !bytes.HasPrefix(blob, []byte("SSHSIG")) {
+		return SignatureBad, ""
+	}
+	var sig sshSignature
+	if err := ssh.Unmarshal(blob[6:], &sig); err != nil || sig.Version != 1 || sig.Namespace != sshNamespace {
+		return SignatureBad, ""
+	}
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return SignatureBad, ""
+	}
+	var inner ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &inner); err != nil {
+		return SignatureBad, ""
+	}
+	// SSHSIG does not allow RSA signatures over SHA-1, and neither does
+	// ssh-keygen -Y verify.
+	if inner.Format == ssh.KeyAlgoRSA {
+		return SignatureBad, ""
+	}
+
+	var hash []byte
+	switch sig.HashAlgorithm {
+	case "sha512":
+		sum := sha512.Sum512(message)
+		hash = sum[:]
+	case "sha256":
+		sum := sha256.Sum256(message)
+		hash = sum[:]
+	default:
+		return SignatureBad, ""
+	}
+	data := append([]byte("SSHSIG"), ssh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          hash,
+	})...)
+	if err := pub.Verify(data, &inner); err != nil {
+		return SignatureBad, ""
+	}
+
+	fingerprint := ssh.FingerprintSHA256(pub)
+	for _, trusted := range k.ssh {
+		if bytes.Equal(trusted.Marshal(), pub.Marshal()) {
+			return SignatureGood, fingerprint
+		}
+	}
+	return SignatureUntrusted, fingerprint
+}
@@ -0,0 +1,461 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// remoteName is the remote sync pushes to and pulls from.
+const remoteName = "origin"
+
+// ErrNoRemote is returned by Sync when no remote is configured.
+var ErrNoRemote = errors.New("no remote configured")
+
+// Resolver settles a file changed on both sides of a merge. Missing
+// versions are nil. It returns the merged content, or nil to delete the
+// file.
+type Resolver func(path string, base, ours, theirs []byte) ([]byte, error)
+
+// SyncResult says what a sync did.
+type SyncResult struct {
+	Pulled  bool
+	Pushed  bool
+	Merged  bool
+	Changed []string
+}
+
+func (r SyncResult) String() string {
+	switch {
+	case r.Merged:
+		return fmt.Sprintf("merged %d changed files", len(r.Changed))
+	case r.Pulled:
+		return fmt.Sprintf("pulled %d changed files", len(r.Changed))
+	case r.Pushed:
+		return "pushed local changes"
+	}
+	return "already up to date"
+}
+
+// Remote returns the URL of the sync remote, or "" if there is none.
+func (r *Repo) Remote() (string, error) {
+	remote, err := r.repo.Remote(remoteName)
+	if err == git.ErrRemoteNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return remote.Config().URLs[0], nil
+}
+
+// SetRemote makes url the sync remote. A local path that does not exist
+// yet is created as a bare repo.
+func (r *Repo) SetRemote(url string) error {
+	if isLocalPath(url) {
+		abs, err := filepath.Abs(url)
+		if err != nil {
+			return err
+		}
+		url = abs
+		if _, err := os.Stat(url); os.IsNotExist(err) {
+			if _, err := git.PlainInit(url, true); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.repo.DeleteRemote(remoteName); err != nil && err != git.ErrRemoteNotFound {
+		return err
+	}
+	_, err := r.repo.CreateRemote(&gitconfig.RemoteConfig{Name: remoteName, URLs: []string{url}})
+	return err
+}
+
+func isLocalPath(url string) bool {
+	return !strings.Contains(url, "://") && !strings.Contains(url, "@")
+}
+
+// Sync fetches from the remote, brings the local branch up to date with
+// it and pushes the result. Diverged histories are merged file by file,
+// calling resolve for files changed on both sides. Local changes must be
+// committed first.
+func (r *Repo) Sync(ctx context.Context, resolve Resolver) (SyncResult, error) {
+	var result SyncResult
+	if url, err := r.Remote(); err != nil {
+		return result, err
+	} else if url == "" {
+		return result, ErrNoRemote
+	}
+
+	// HEAD names the branch even before its first commit.
+	head, err := r.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return result, err
+	}
+	branch := head.Target()
+	local, err := r.repo.Reference(branch, true)
+	if err == plumbing.ErrReferenceNotFound {
+		local = nil
+	} else if err != nil {
+		return result, err
+	}
+
+	err = r.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%s:refs/remotes/%s/%s", branch, remoteName, branch.Short()))},
+	})
+	switch {
+	case errors.Is(err, transport.ErrEmptyRemoteRepository), isMissingRef(err):
+		if local == nil {
+			return result, nil
+		}
+		return result, r.push(ctx, branch, &result)
+	case err != nil && err != git.NoErrAlreadyUpToDate:
+		return result, fmt.Errorf("fetch: %w", err)
+	}
+
+	remoteRef, err := r.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, branch.Short()), true)
+	if err == plumbing.ErrReferenceNotFound && local != nil {
+		return result, r.push(ctx, branch, &result)
+	}
+	if err != nil {
+		return result, err
+	}
+	theirs, err := r.repo.CommitObject(remoteRef.Hash())
+	if err != nil {
+		return result, err
+	}
+	if local == nil {
+		if result.Changed, err = r.fastForward(branch, nil, theirs); err != nil {
+			return result, err
+		}
+		result.Pulled = true
+		return result, nil
+	}
+	ours, err := r.repo.CommitObject(local.Hash())
+	if err != nil {
+		return result, err
+	}
+
+	switch {
+	case ours.Hash == theirs.Hash:
+		return result, nil
+	case isAncestor(theirs, ours):
+		return result, r.push(ctx, branch, &result)
+	case isAncestor(ours, theirs):
+		if result.Changed, err = r.fastForward(branch, ours, theirs); err != nil {
+			return result, err
+		}
+		result.Pulled = true
+		return result, nil
+	}
+
+	if result.Changed, err = r.merge(ours, theirs, "Merge remote changes", resolve); err != nil {
+		return result, err
+	}
+	result.Pulled, result.Merged = true, true
+	return result, r.push(ctx, branch, &result)
+}
+
+func isMissingRef(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "couldn't find remote ref")
+}
+
+func isAncestor(c, of *object.Commit) bool {
+	ok, err := c.IsAncestor(of)
+	return err == nil && ok
+}
+
+func (r *Repo) push(ctx context.Context, branch plumbing.ReferenceName, result *SyncResult) error {
+	err := r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(branch + ":" + branch)},
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("push: %w", err)
+	}
+	result.Pushed = true
+	return nil
+}
+
+// Replace force-pushes the current branch after its history was rewritten
+// from old, which the remote must still have, and points the remote-tracking
+// refs at the result so they no longer keep the old history. If the push
+// fails, the branch is moved back to old, so that the next sync does not
+// merge the old history into the new one.
+func (r *Repo) Replace(ctx context.Context, old string) error {
+	branch, head, err := r.headBranch()
+	if err != nil {
+		return err
+	}
+	tracking := plumbing.NewRemoteReferenceName(remoteName, branch.Short())
+	oldHash := plumbing.NewHash(old)
+	// The lease is checked against the remote-tracking ref, which a first
+	// push leaves unset.
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(tracking, oldHash)); err != nil {
+		return err
+	}
+	err = r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName:     remoteName,
+		RefSpecs:       []gitconfig.RefSpec{gitconfig.RefSpec("+" + branch + ":" + branch)},
+		ForceWithLease: &git.ForceWithLease{RefName: branch, Hash: oldHash},
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		if rerr := r.repo.Storer.SetReference(plumbing.NewHashReference(branch, oldHash)); rerr != nil {
+			return rerr
+		}
+		return fmt.Errorf("push: %w", err)
+	}
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name().IsRemote() && ref.Name() != tracking {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := r.repo.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+	return r.repo.Storer.SetReference(plumbing.NewHashReference(tracking, head))
+}
+
+// fastForward moves the branch to theirs and checks it out. Ours is nil
+// for a branch without commits.
+func (r *Repo) fastForward(branch plumbing.ReferenceName, ours, theirs *object.Commit) ([]string, error) {
+	ourFiles, err := treeFiles(ours)
+	if err != nil {
+		return nil, err
+	}
+	theirFiles, err := treeFiles(theirs)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for p, f := range theirFiles {
+		if fileHash(ourFiles[p]) != f.Hash {
+			changed = append(changed, p)
+		}
+	}
+	for p := range ourFiles {
+		if theirFiles[p] == nil {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	w, err := r.worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(branch, theirs.Hash)); err != nil {
+		return nil, err
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: theirs.Hash, Mode: git.HardReset}); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// merge combines ours and theirs into a merge commit on the current
+// branch. Files changed on one side only take that side's version; files
+// changed on both go through resolve.
+func (r *Repo) merge(ours, theirs *object.Commit, message string, resolve Resolver) ([]string, error) {
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return nil, err
+	}
+	var base *object.Commit
+	if len(bases) > 0 {
+		base = bases[0]
+	}
+
+	baseFiles, err := treeFiles(base)
+	if err != nil {
+		return nil, err
+	}
+	ourFiles, err := treeFiles(ours)
+	if err != nil {
+		return nil, err
+	}
+	theirFiles, err := treeFiles(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for _, files := range []map[string]*object.File{baseFiles, ourFiles, theirFiles} {
+		for p := range files {
+			paths[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	w, err := r.worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, p := range sorted {
+		b, o, t := fileHash(baseFiles[p]), fileHash(ourFiles[p]), fileHash(theirFiles[p])
+		if o == t || t == b {
+			continue
+		}
+		var content []byte
+		if o == b {
+			if content, err = fileContent(theirFiles[p]); err != nil {
+				return nil, err
+			}
+		} else {
+			contents := make([][]byte, 3)
+			for i, f := range []*object.File{baseFiles[p], ourFiles[p], theirFiles[p]} {
+				if contents[i], err = fileContent(f); err != nil {
+					return nil, err
+				}
+			}
+			if content, err = resolve(p, contents[0], contents[1], contents[2]); err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+		}
+
+		full := filepath.Join(r.path, filepath.FromSlash(p))
+		if content == nil {
+			if o == plumbing.ZeroHash {
+				continue
+			}
+			if _, err := w.Remove(p); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(full, content, 0644); err != nil {
+				return nil, err
+			}
+			if _, err := w.Add(p); err != nil {
+				return nil, err
+			}
+		}
+		changed = append(changed, p)
+	}
+
+	opts := r.commitOptions()
+	opts.Parents = []plumbing.Hash{ours.Hash, theirs.Hash}
+	opts.AllowEmptyCommits = true
+	_, err = w.Commit(message, opts)
+	return changed, err
+}
+
+func treeFiles(c *object.Commit) (map[string]*object.File, error) {
+	files := map[string]*object.File{}
+	if c == nil {
+		return files, nil
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files[f.Name] = f
+		return nil
+	})
+	return files, err
+}
+
+func fileHash(f *object.File) plumbing.Hash {
+	if f == nil {
+		return plumbing.ZeroHash
+	}
+	return f.Hash
+}
+
+func fileContent(f *object.File) ([]byte, error) {
+	if f == nil {
+		return nil, nil
+	}
+	rd, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
+
+func signature() *object.Signature {
+	return &object.Signature{Name: "Invar", Email: "invar@localhost", When: time.Now()}
+}
+
+// InstallMergeDriver registers command as a git merge driver called name
+// and uses it for files matching patterns, so merges run with plain git
+// use it too. The setup is local to this clone.
+func (r *Repo) InstallMergeDriver(name, command string, patterns []string) error {
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+	sub := cfg.Raw.Section("merge").Subsection(name)
+	sub.SetOption("name", "invar task merge")
+	sub.SetOption("driver", command)
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	attrs := filepath.Join(r.path, ".git", "info", "attributes")
+	existing, err := os.ReadFile(attrs)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(string(existing), "\n")
+	var add []string
+	for _, p := range patterns {
+		line := p + " merge=" + name
+		if !slices.Contains(lines, line) {
+			add = append(add, line)
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(attrs), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(attrs, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		fmt.Fprintln(f)
+	}
+	fmt.Fprintln(f, strings.Join(add, "\n"))
+	return f.Close()
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"context"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+func init() {
+	// Serve file remotes in-process, so a bare repo on a shared drive works
+	// without git installed.
+	client.InstallProtocol("file", fileTransport{server.DefaultServer})
+}
+
+// fileTransport is go-git's in-process server, fixed to cope with fetches
+// from a repo that has commits the remote lacks. Real git ignores such
+// "have" lines; go-git's server fails on them.
+type fileTransport struct {
+	transport.Transport
+}
+
+func (t fileTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	session, err := t.Transport.NewUploadPackSession(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	st, err := server.DefaultLoader.Load(ep)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &uploadSession{UploadPackSession: session, has: func(h plumbing.Hash) bool {
+		return st.HasEncodedObject(h) == nil
+	}}, nil
+}
+
+type uploadSession struct {
+	transport.UploadPackSession
+	has func(plumbing.Hash) bool
+}
+
+func (s *uploadSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	haves := req.Haves[:0]
+	for _, h := range req.Haves {
+		if s.has(h) {
+			haves = append(haves, h)
+		}
+	}
+	req.Haves = haves
+	return s.UploadPackSession.UploadPack(ctx, req)
+}
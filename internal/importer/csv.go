@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/date"
+	"github.com/user/invar/internal/task"
+)
+
+// Mapping maps task fields to CSV column names. Fields not in the mapping
+// are looked up under their own name and a few common aliases.
+type Mapping map[string]string
+
+// csvFields lists the task fields a CSV column can map to, with the column
+// names tried when the mapping does not name one.
+var csvFields = map[string][]string{
+	"content":   {"content", "description", "task", "title", "name"},
+	"priority":  {"priority", "prio"},
+	"deadline":  {"deadline", "due", "due date"},
+	"tags":      {"tags", "labels", "tag"},
+	"completed": {"completed", "done", "completed at", "status"},
+	"created":   {"created", "created at", "entry"},
+	"archived":  {"archived"},
+}
+
+// ParseMapping parses a mapping such as "content=Title,deadline=Due".
+func ParseMapping(s string) (Mapping, error) {
+	m := Mapping{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid mapping %q (want field=column)", pair)
+		}
+		if _, known := csvFields[field]; !known {
+			return nil, fmt.Errorf("unknown field %q in mapping", field)
+		}
+		m[field] = strings.TrimSpace(column)
+	}
+	return m, nil
+}
+
+// ParseCSV reads a CSV file with a header row, such as one written by the
+// CSV exporter. Dates are RFC 3339 timestamps or anything the deadline input
+// accepts, tags are separated by commas or semicolons, and the completed
+// column may hold a date or a yes/no value.
+func ParseCSV(r io.Reader, mapping Mapping) ([]*task.Task, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for field, aliases := range csvFields {
+		names := aliases
+		if col, ok := mapping[field]; ok {
+			names = []string{col}
+		}
+		for i, h := range header {
+			if containsFold(names, strings.TrimSpace(h)) {
+				columns[field] = i
+				break
+			}
+		}
+		if _, ok := columns[field]; !ok && mapping[field] != "" {
+			return nil, fmt.Errorf("column %q not found in header", mapping[field])
+		}
+	}
+	if _, ok := columns["content"]; !ok {
+		return nil, fmt.Errorf("no content column; map one with content=<column>")
+	}
+
+	var tasks []*task.Task
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if get("content") == "" {
+			continue
+		}
+		t, err := convertCSV(get)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func convertCSV(get func(string) string) (*task.Task, error) {
+	var created time.Time
+	if s := get("created"); s != "" {
+		d, err := parseCSVDate(s)
+		if err != nil {
+			return nil, err
+		}
+		created = *d
+	}
+	t := newTask(get("content"), created)
+
+	if s := get("priority"); s != "" {
+		p, ok := parsePriority(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid priority %q", s)
+		}
+		t.Priority = p
+	}
+	if s := get("deadline"); s != "" {
+		d, err := parseCSVDate(s)
+		if err != nil {
+			return nil, err
+		}
+		t.Deadline = d
+	}
+	if s := get("tags"); s != "" {
+		for _, tag := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	}
+	if s := get("completed"); s != "" {
+		switch strings.ToLower(s) {
+		case "yes", "y", "true", "x", "1", "done", "completed":
+			now := t.UpdatedAt
+			t.CompletedAt = &now
+		case "no", "n", "false", "0", "pending", "todo":
+		default:
+			d, err := parseCSVDate(s)
+			if err != nil {
+				return nil, err
+			}
+			t.CompletedAt = d
+		}
+	}
+	if s := get("archived"); s != "" {
+		switch strings.ToLower(s) {
+		case "yes", "y", "true", "x", "1":
+			t.Archive()
+		case "no", "n", "false", "0":
+		default:
+			return nil, fmt.Errorf("invalid archived value %q (want yes or no)", s)
+		}
+	}
+	return t, nil
+}
+
+// parseCSVDate reads a timestamp in the RFC 3339 form the CSV exporter
+// writes, or anything the deadline input accepts.
+func parseCSVDate(s string) (*time.Time, error) {
+	if d, err := time.Parse(time.RFC3339, s); err == nil {
+		return &d, nil
+	}
+	d, err := date.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("invalid date %q", s)
+	}
+	return d, nil
+}
+
+func containsFold(names []string, s string) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, s) {
+			return true
+		}
+	}
+	return false
+}
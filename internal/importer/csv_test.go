@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/invar/internal/export"
+	"github.com/user/invar/internal/task"
+)
+
+// TestCSVRoundTrip checks that what the CSV exporter writes imports back
+// as the same tasks.
+func TestCSVRoundTrip(t *testing.T) {
+	created := time.Date(2026, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	deadline := time.Date(2026, 11, 2, 15, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 3, 4, 18, 5, 7, 0, time.UTC)
+
+	plain := task.New("Buy milk")
+	plain.CreatedAt, plain.UpdatedAt = created, created
+	full := task.New("Write report, with \"quotes\"\nand a second line")
+	full.CreatedAt, full.UpdatedAt = created, created
+	full.Priority = task.PriorityHigh
+	full.Deadline = &deadline
+	full.Tags = []string{"work", "q4 review"}
+	full.CompletedAt = &completed
+	full.Archive()
+	in := []*task.Task{plain, full}
+
+	exporter, err := export.Get("csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ParseCSV(&buf, Mapping{})
+	if err != nil {
+		t.Fatalf("importing the export: %v", err)
+	}
+	if len(out) != len(in) {
+		t.Fatalf("imported %d tasks, want %d", len(out), len(in))
+	}
+
+	for i, want := range in {
+		got := out[i]
+		if got.Content != want.Content || got.Priority != want.Priority || got.Archived != want.Archived {
+			t.Errorf("task %d: got %q %s archived=%v, want %q %s archived=%v", i,
+				got.Content, got.Priority, got.Archived, want.Content, want.Priority, want.Archived)
+		}
+		if !reflect.DeepEqual(got.Tags, want.Tags) && len(got.Tags)+len(want.Tags) > 0 {
+			t.Errorf("task %d: tags %q, want %q", i, got.Tags, want.Tags)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("task %d: created %v, want %v", i, got.CreatedAt, want.CreatedAt)
+		}
+		if !sameTime(got.Deadline, want.Deadline) {
+			t.Errorf("task %d: deadline %v, want %v", i, got.Deadline, want.Deadline)
+		}
+		if !sameTime(got.CompletedAt, want.CompletedAt) {
+			t.Errorf("task %d: completed %v, want %v", i, got.CompletedAt, want.CompletedAt)
+		}
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// Format names an import source.
+type Format string
+
+const (
+	FormatTodoTxt     Format = "todotxt"
+	FormatTaskwarrior Format = "taskwarrior"
+	FormatCSV         Format = "csv"
+)
+
+// Parse reads tasks in the given format. The mapping is only used for CSV
+// and may be nil.
+func Parse(f Format, r io.Reader, mapping Mapping) ([]*task.Task, error) {
+	switch f {
+	case FormatTodoTxt:
+		return ParseTodoTxt(r)
+	case FormatTaskwarrior:
+		return ParseTaskwarrior(r)
+	case FormatCSV:
+		return ParseCSV(r, mapping)
+	}
+	return nil, fmt.Errorf("unknown import format %q (want todotxt, taskwarrior or csv)", f)
+}
+
+// Dedupe splits incoming tasks into new ones and duplicates. A task is a
+// duplicate if its ID is already taken or its content matches an existing
+// task or an earlier incoming one, ignoring case and whitespace.
+func Dedupe(existing, incoming []*task.Task) (fresh, dups []*task.Task) {
+	ids := map[string]bool{}
+	contents := map[string]bool{}
+	for _, t := range existing {
+		ids[t.ID] = true
+		contents[normalize(t.Content)] = true
+	}
+	for _, t := range incoming {
+		key := normalize(t.Content)
+		if ids[t.ID] || contents[key] {
+			dups = append(dups, t)
+			continue
+		}
+		ids[t.ID] = true
+		contents[key] = true
+		fresh = append(fresh, t)
+	}
+	return fresh, dups
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// parsePriority maps the priority spellings used by other tools onto
+// invar's three levels.
+func parsePriority(s string) (task.Priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high", "h", "a", "1":
+		return task.PriorityHigh, true
+	case "medium", "med", "m", "b", "2":
+		return task.PriorityMedium, true
+	case "low", "l", "c", "3":
+		return task.PriorityLow, true
+	}
+	return "", false
+}
+
+// endOfDay turns a date into a deadline at 23:59 local time, matching the
+// deadlines set from the TUI.
+func endOfDay(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 23, 59, 0, 0, time.Local)
+}
+
+// newTask creates a task with creation and update times set to created,
+// or to now when created is zero.
+func newTask(content string, created time.Time) *task.Task {
+	t := task.New(content)
+	if !created.IsZero() {
+		t.CreatedAt = created
+		t.UpdatedAt = created
+	}
+	return t
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/invar/internal/task"
+)
+
+// urgencyHigh is the Taskwarrior urgency above which a task without an
+// explicit priority is imported as high priority.
+const urgencyHigh = 8.0
+
+type twAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+type twTask struct {
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	Entry       string         `json:"entry"`
+	Modified    string         `json:"modified"`
+	End         string         `json:"end"`
+	Due         string         `json:"due"`
+	Priority    string         `json:"priority"`
+	Project     string         `json:"project"`
+	Tags        []string       `json:"tags"`
+	Annotations []twAnnotation `json:"annotations"`
+	Urgency     float64        `json:"urgency"`
+}
+
+// ParseTaskwarrior reads the output of `task export`, either as a JSON
+// array or as one JSON object per line. Deleted tasks are skipped. The
+// Taskwarrior UUID is kept as the task ID, annotations become comments and
+// the project becomes a tag. Tasks without a priority are imported as high
+// priority when their urgency is at least 8.
+func ParseTaskwarrior(r io.Reader) ([]*task.Task, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []twTask
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var tw twTask
+			if err := json.Unmarshal(line, &tw); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			raw = append(raw, tw)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var tasks []*task.Task
+	for i, tw := range raw {
+		if tw.Status == "deleted" {
+			continue
+		}
+		t, err := convertTaskwarrior(tw)
+		if err != nil {
+			return nil, fmt.Errorf("task %d (%s): %w", i+1, tw.UUID, err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func convertTaskwarrior(tw twTask) (*task.Task, error) {
+	if strings.TrimSpace(tw.Description) == "" {
+		return nil, fmt.Errorf("task has no description")
+	}
+	entry, err := twTime(tw.Entry)
+	if err != nil {
+		return nil, err
+	}
+
+	t := newTask(tw.Description, entry)
+	if tw.UUID != "" {
+		// The ID names the task's file, so it must not be able to point
+		// anywhere else.
+		id, err := uuid.Parse(tw.UUID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uuid %q", tw.UUID)
+		}
+		t.ID = id.String()
+	}
+	if modified, err := twTime(tw.Modified); err != nil {
+		return nil, err
+	} else if !modified.IsZero() {
+		t.UpdatedAt = modified
+	}
+
+	if p, ok := parsePriority(tw.Priority); ok {
+		t.Priority = p
+	} else if tw.Urgency >= urgencyHigh {
+		t.Priority = task.PriorityHigh
+	}
+
+	due, err := twTime(tw.Due)
+	if err != nil {
+		return nil, err
+	}
+	if !due.IsZero() {
+		t.Deadline = &due
+	}
+
+	if tw.Status == "completed" {
+		end, err := twTime(tw.End)
+		if err != nil {
+			return nil, err
+		}
+		if end.IsZero() {
+			end = t.UpdatedAt
+		}
+		t.CompletedAt = &end
+	}
+
+	if tw.Project != "" {
+		t.Tags = append(t.Tags, tw.Project)
+	}
+	t.Tags = append(t.Tags, tw.Tags...)
+
+	for _, a := range tw.Annotations {
+		when, err := twTime(a.Entry)
+		if err != nil {
+			return nil, err
+		}
+		t.Comments = append(t.Comments, task.Comment{Text: a.Description, CreatedAt: when})
+	}
+	return t, nil
+}
+
+// twTime parses Taskwarrior's compact ISO 8601 timestamps. An empty
+// string yields the zero time.
+func twTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"20060102T150405Z", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Local(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
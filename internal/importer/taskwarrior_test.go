@@ -0,0 +1,27 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTaskwarriorUUID checks that a task keeps its Taskwarrior UUID as its
+// ID, and that a uuid that is not one is refused rather than used to name
+// a file.
+func TestTaskwarriorUUID(t *testing.T) {
+	const id = "9f6a2c1e-8d3b-4f5a-9c7e-1b2d3e4f5a6b"
+	tasks, err := ParseTaskwarrior(strings.NewReader(`[{"uuid":"` + strings.ToUpper(id) + `","description":"kept"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != id {
+		t.Fatalf("tasks = %+v, want one with ID %s", tasks, id)
+	}
+
+	for _, uuid := range []string{"../../../tmp/x", "a/b", "x", "9f6a2c1e-8d3b-4f5a-9c7e-1b2d3e4f5a6b/.."} {
+		_, err := ParseTaskwarrior(strings.NewReader(`[{"uuid":"` + uuid + `","description":"hostile"}]`))
+		if err == nil || !strings.Contains(err.Error(), "invalid uuid") {
+			t.Errorf("uuid %q: err = %v, want an invalid uuid error", uuid, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+var (
+	todoPriority = regexp.MustCompile(`^\(([A-Z])\) `)
+	todoDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+)
+
+// ParseTodoTxt reads a todo.txt file. Priorities (A) and (B) map to high
+// and medium, anything lower to low. Projects and contexts become tags,
+// due:YYYY-MM-DD becomes the deadline, and completion and creation dates
+// are kept. Other key:value extensions are dropped.
+func ParseTodoTxt(r io.Reader) ([]*task.Task, error) {
+	var tasks []*task.Task
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		t, err := parseTodoLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, scanner.Err()
+}
+
+func parseTodoLine(line string) (*task.Task, error) {
+	var completed, created time.Time
+	done := false
+
+	if strings.HasPrefix(line, "x ") {
+		done = true
+		line = strings.TrimSpace(line[2:])
+		if d, rest, ok := cutDate(line); ok {
+			completed, line = d, rest
+		}
+	}
+
+	priority := task.PriorityMedium
+	if m := todoPriority.FindStringSubmatch(line); m != nil {
+		switch m[1] {
+		case "A":
+			priority = task.PriorityHigh
+		case "B":
+			priority = task.PriorityMedium
+		default:
+			priority = task.PriorityLow
+		}
+		line = line[len(m[0]):]
+	}
+
+	if d, rest, ok := cutDate(line); ok {
+		created, line = d, rest
+	}
+
+	var words, tags []string
+	var deadline *time.Time
+	for _, word := range strings.Fields(line) {
+		switch {
+		case len(word) > 1 && (word[0] == '+' || word[0] == '@'):
+			tags = append(tags, word[1:])
+		case strings.HasPrefix(word, "due:"):
+			d, err := time.ParseInLocation("2006-01-02", word[4:], time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("invalid due date %q", word[4:])
+			}
+			eod := endOfDay(d)
+			deadline = &eod
+		case isTodoExtension(word):
+		default:
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("task has no description")
+	}
+
+	t := newTask(strings.Join(words, " "), created)
+	t.Priority = priority
+	t.Deadline = deadline
+	if tags != nil {
+		t.Tags = tags
+	}
+	if done {
+		if completed.IsZero() {
+			completed = t.UpdatedAt
+		}
+		t.CompletedAt = &completed
+	}
+	return t, nil
+}
+
+// cutDate strips a leading YYYY-MM-DD date from s.
+func cutDate(s string) (time.Time, string, bool) {
+	if !todoDate.MatchString(s) {
+		return time.Time{}, s, false
+	}
+	d, err := time.ParseInLocation("2006-01-02", s[:10], time.Local)
+	if err != nil {
+		return time.Time{}, s, false
+	}
+	return d, strings.TrimSpace(s[11:]), true
+}
+
+// isTodoExtension reports whether word is a key:value tag such as
+// "rec:1w". URLs are kept as part of the description.
+func isTodoExtension(word string) bool {
+	key, value, ok := strings.Cut(word, ":")
+	if !ok || key == "" || value == "" || strings.HasPrefix(value, "//") {
+		return false
+	}
+	return !strings.ContainsAny(key, "/.")
+}
@@ -0,0 +1,206 @@
+// Package merge combines two versions of a task that were edited
+// independently from a common base.
+package merge
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// Conflict is a field both sides changed to different values. Conflicts
+// are settled by keeping the value from the side updated last; when both
+// sides have the same update time the conflict is left unresolved, keeping
+// our value, for the user to decide.
+type Conflict struct {
+	Field    string `json:"field"`
+	Ours     string `json:"ours"`
+	Theirs   string `json:"theirs"`
+	Resolved bool   `json:"resolved"`
+	// Winner is "ours" or "theirs", the side whose value was kept.
+	Winner string `json:"winner"`
+}
+
+func (c Conflict) String() string {
+	if !c.Resolved {
+		return fmt.Sprintf("%s: %q here, %q on the other side", c.Field, c.Ours, c.Theirs)
+	}
+	kept, lost := c.Ours, c.Theirs
+	if c.Winner == "theirs" {
+		kept, lost = lost, kept
+	}
+	return fmt.Sprintf("%s: kept newer %q over %q", c.Field, kept, lost)
+}
+
+// Fields lists the task fields Tasks merges one by one, in the names used
+// by Conflict.
+var Fields = []string{"content", "priority", "deadline", "completed", "archived", "deleted"}
+
+// Tasks merges ours and theirs, two versions of a task derived from base.
+// Base is nil when both sides created the task. Fields changed on one side
+// take that side's value, tags take the changes from both sides, and
+// comments are the union of both.
+func Tasks(base, ours, theirs *task.Task) (*task.Task, []Conflict) {
+	if base == nil {
+		base = &task.Task{}
+	}
+	m := &merger{
+		newer: theirs.UpdatedAt.After(ours.UpdatedAt),
+		tie:   theirs.UpdatedAt.Equal(ours.UpdatedAt),
+	}
+
+	merged := *ours
+	merged.Content = field(m, "content", base.Content, ours.Content, theirs.Content, eq, quote)
+	merged.Priority = field(m, "priority", base.Priority, ours.Priority, theirs.Priority, eq, func(p task.Priority) string { return string(p) })
+	merged.Deadline = field(m, "deadline", base.Deadline, ours.Deadline, theirs.Deadline, timeEq, showTime)
+	merged.CompletedAt = field(m, "completed", base.CompletedAt, ours.CompletedAt, theirs.CompletedAt, timeEq, showTime)
+	archived := field(m, "archived", archiveState(base), archiveState(ours), archiveState(theirs), archiveEq, showArchive)
+	merged.Archived, merged.ArchivedAt = archived.archived, archived.at
+	merged.DeletedAt = field(m, "deleted", base.DeletedAt, ours.DeletedAt, theirs.DeletedAt, timeEq, showTime)
+
+	merged.Tags = mergeTags(base.Tags, ours.Tags, theirs.Tags)
+	merged.Comments = mergeComments(ours.Comments, theirs.Comments)
+	if theirs.UpdatedAt.After(merged.UpdatedAt) {
+		merged.UpdatedAt = theirs.UpdatedAt
+	}
+	if !theirs.CreatedAt.IsZero() && theirs.CreatedAt.Before(merged.CreatedAt) {
+		merged.CreatedAt = theirs.CreatedAt
+	}
+	return &merged, m.conflicts
+}
+
+// Apply copies a field, named as in Fields, from src to dst. It is used to
+// settle a conflict by hand.
+func Apply(dst, src *task.Task, field string) error {
+	switch field {
+	case "content":
+		dst.Content = src.Content
+	case "priority":
+		dst.Priority = src.Priority
+	case "deadline":
+		dst.Deadline = src.Deadline
+	case "completed":
+		dst.CompletedAt = src.CompletedAt
+	case "archived":
+		dst.Archived, dst.ArchivedAt = src.Archived, src.ArchivedAt
+	case "deleted":
+		dst.DeletedAt = src.DeletedAt
+	default:
+		return fmt.Errorf("unknown field %q (want %s)", field, strings.Join(Fields, ", "))
+	}
+	dst.UpdatedAt = time.Now()
+	return nil
+}
+
+type merger struct {
+	newer     bool
+	tie       bool
+	conflicts []Conflict
+}
+
+func field[T any](m *merger, name string, base, ours, theirs T, eq func(a, b T) bool, show func(T) string) T {
+	switch {
+	case eq(ours, theirs), eq(theirs, base):
+		return ours
+	case eq(ours, base):
+		return theirs
+	}
+	c := Conflict{Field: name, Ours: show(ours), Theirs: show(theirs), Resolved: !m.tie, Winner: "ours"}
+	if m.newer {
+		c.Winner = "theirs"
+	}
+	m.conflicts = append(m.conflicts, c)
+	if m.newer {
+		return theirs
+	}
+	return ours
+}
+
+func eq[T comparable](a, b T) bool { return a == b }
+
+func quote(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + "…"
+	}
+	return s
+}
+
+func timeEq(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func showTime(t *time.Time) string {
+	if t == nil {
+		return "none"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// archive keeps the archived flag and its time together, so a merge never
+// pairs one side's flag with the other's time.
+type archive struct {
+	archived bool
+	at       *time.Time
+}
+
+func archiveState(t *task.Task) archive {
+	return archive{t.Archived, t.ArchivedAt}
+}
+
+func archiveEq(a, b archive) bool {
+	return a.archived == b.archived && timeEq(a.at, b.at)
+}
+
+func showArchive(a archive) string {
+	if !a.archived {
+		return "no"
+	}
+	return "yes"
+}
+
+// mergeTags applies the tags added and removed on both sides to base.
+func mergeTags(base, ours, theirs []string) []string {
+	result := slices.Clone(base)
+	for _, side := range [][]string{ours, theirs} {
+		for _, tag := range side {
+			if !slices.Contains(base, tag) && !slices.Contains(result, tag) {
+				result = append(result, tag)
+			}
+		}
+		for _, tag := range base {
+			if !slices.Contains(side, tag) {
+				result = slices.DeleteFunc(result, func(t string) bool { return t == tag })
+			}
+		}
+	}
+	if result == nil {
+		result = []string{}
+	}
+	return result
+}
+
+// mergeComments returns the comments of both sides, oldest first, with
+// the ones both sides have listed once.
+func mergeComments(ours, theirs []task.Comment) []task.Comment {
+	if len(ours)+len(theirs) == 0 {
+		return ours
+	}
+	var result []task.Comment
+	seen := map[string]bool{}
+	for _, c := range append(slices.Clone(ours), theirs...) {
+		k := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "\x00" + c.Text
+		if !seen[k] {
+			seen[k] = true
+			result = append(result, c)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result
+}
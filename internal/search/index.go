@@ -0,0 +1,284 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+// State says where a search result lives.
+type State string
+
+const (
+	StateActive   State = "active"
+	StateArchived State = "archived"
+	StateTrashed  State = "trashed"
+	StateDeleted  State = "deleted"
+)
+
+// Field weights: a hit in a tag says more about a task than a hit in a
+// long comment thread.
+const (
+	weightContent = 1.0
+	weightTag     = 3.0
+	weightComment = 0.5
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type doc struct {
+	task   *task.Task
+	state  State
+	terms  map[string]float64
+	length float64
+}
+
+// Result is a ranked match.
+type Result struct {
+	Task  *task.Task
+	State State
+	Score float64
+}
+
+// Index is an in-memory full-text index over task content, tags and
+// comments. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*doc
+	postings map[string]map[string]float64
+	total    float64
+}
+
+func New() *Index {
+	return &Index{
+		docs:     map[string]*doc{},
+		postings: map[string]map[string]float64{},
+	}
+}
+
+// Build indexes every active, archived and trashed task in the store, and
+// with history set also the last version of every task deleted from the
+// repo.
+func Build(s *storage.Store, history bool) (*Index, error) {
+	idx := New()
+	for _, archived := range []bool{false, true} {
+		tasks, err := s.List(archived)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			idx.Add(t, stateOf(t))
+		}
+	}
+	trashed, err := s.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trashed {
+		idx.Add(t, StateTrashed)
+	}
+
+	if history {
+		if err := idx.AddDeleted(s); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// AddDeleted indexes the last version of every task deleted from the repo,
+// as recovered from the git history.
+func (idx *Index) AddDeleted(s *storage.Store) error {
+	deleted, err := s.Deleted()
+	if err != nil {
+		return err
+	}
+	for _, d := range deleted {
+		idx.Add(d.Task, StateDeleted)
+	}
+	return nil
+}
+
+// Attach keeps the index up to date with changes made through the store.
+func (idx *Index) Attach(s *storage.Store) {
+	s.Subscribe(func(e storage.Event) {
+		switch e.Type {
+		case storage.EventSaved, storage.EventRestored:
+			idx.Add(e.Task, stateOf(e.Task))
+		case storage.EventTrashed:
+			idx.Add(e.Task, StateTrashed)
+		case storage.EventPurged:
+			idx.Remove(e.ID)
+		case storage.EventSynced, storage.EventSwitched:
+			idx.reload(s)
+		}
+	})
+}
+
+// reload replaces the indexed tasks with what is in the store now. Tasks
+// deleted from the repo stay indexed, since a sync cannot bring them back.
+func (idx *Index) reload(s *storage.Store) {
+	fresh, err := Build(s, false)
+	if err != nil {
+		return
+	}
+	idx.mu.Lock()
+	var deleted []*doc
+	for _, d := range idx.docs {
+		if d.state == StateDeleted && fresh.docs[d.task.ID] == nil {
+			deleted = append(deleted, d)
+		}
+	}
+	idx.docs, idx.postings, idx.total = fresh.docs, fresh.postings, fresh.total
+	idx.mu.Unlock()
+	for _, d := range deleted {
+		idx.Add(d.task, StateDeleted)
+	}
+}
+
+func stateOf(t *task.Task) State {
+	switch {
+	case t.DeletedAt != nil:
+		return StateTrashed
+	case t.Archived:
+		return StateArchived
+	}
+	return StateActive
+}
+
+// Add indexes a task, replacing any earlier version with the same ID.
+func (idx *Index) Add(t *task.Task, state State) {
+	d := &doc{task: t, state: state, terms: map[string]float64{}}
+	for _, term := range Tokenize(t.Content) {
+		d.terms[term] += weightContent
+	}
+	for _, tag := range t.Tags {
+		for _, term := range Tokenize(tag) {
+			d.terms[term] += weightTag
+		}
+	}
+	for _, c := range t.Comments {
+		for _, term := range Tokenize(c.Text) {
+			d.terms[term] += weightComment
+		}
+	}
+	for _, w := range d.terms {
+		d.length += w
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(t.ID)
+	idx.docs[t.ID] = d
+	idx.total += d.length
+	for term, w := range d.terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = map[string]float64{}
+		}
+		idx.postings[term][t.ID] = w
+	}
+}
+
+// Remove drops a task from the index.
+func (idx *Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id string) {
+	d, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for term := range d.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.total -= d.length
+	delete(idx.docs, id)
+}
+
+// Search returns the tasks matching every term of the query, best match
+// first, ranked with BM25. Query terms also match longer words they are a
+// prefix of, at a lower score than an exact match. A limit of 0 returns
+// all matches.
+func (idx *Index) Search(query string, limit int) []Result {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docs))
+	avg := idx.total / max(n, 1)
+	scores := map[string]float64{}
+	for i, qt := range terms {
+		matched := map[string]float64{}
+		for term, posting := range idx.postings {
+			if !strings.HasPrefix(term, qt) {
+				continue
+			}
+			boost := 1.0
+			if term != qt {
+				boost = 0.5
+			}
+			df := float64(len(posting))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, tf := range posting {
+				dl := idx.docs[id].length
+				s := idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*dl/avg))
+				matched[id] = max(matched[id], s*boost)
+			}
+		}
+
+		// Every term has to match: drop documents missed by this one.
+		if i == 0 {
+			scores = matched
+			continue
+		}
+		for id := range scores {
+			if s, ok := matched[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		d := idx.docs[id]
+		results = append(results, Result{Task: d.task, State: d.state, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Task.UpdatedAt.After(results[j].Task.UpdatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Tokenize lowercases s and splits it into words of letters and digits.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,279 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/user/invar/internal/storage"
+	"github.com/user/invar/internal/task"
+)
+
+// Default windows, in periods, when Options.Since is not set.
+const (
+	defaultDays  = 30
+	defaultWeeks = 12
+)
+
+// Options selects what Compute covers. Periods are days, or ISO weeks with
+// Weekly, from the one holding Since up to the one holding now.
+type Options struct {
+	Weekly bool
+	Since  time.Time
+}
+
+// Period counts what happened in one day or week. Open is how many tasks
+// were still open at its end, which makes a burndown over the periods.
+type Period struct {
+	Start     time.Time `json:"start"`
+	Created   int       `json:"created"`
+	Completed int       `json:"completed"`
+	Open      int       `json:"open"`
+}
+
+// Bucket is one bar of a histogram.
+type Bucket struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// LeadTime is the time from creation to completion of the tasks completed
+// in the window.
+type LeadTime struct {
+	Count      int      `json:"count"`
+	MeanDays   float64  `json:"mean_days"`
+	MedianDays float64  `json:"median_days"`
+	P90Days    float64  `json:"p90_days"`
+	Histogram  []Bucket `json:"histogram"`
+}
+
+// Overdue counts the deadlines that fell in the window: Late of them were
+// completed after the deadline or not at all. Open is how many tasks are
+// overdue now.
+type Overdue struct {
+	Due  int     `json:"due"`
+	Late int     `json:"late"`
+	Rate float64 `json:"rate"`
+	Open int     `json:"open"`
+}
+
+// PriorityMix counts the open tasks and the tasks completed in the window
+// with one priority.
+type PriorityMix struct {
+	Priority  task.Priority `json:"priority"`
+	Open      int           `json:"open"`
+	Completed int           `json:"completed"`
+}
+
+// Report holds the statistics for a window.
+type Report struct {
+	By         string        `json:"by"`
+	Since      time.Time     `json:"since"`
+	Until      time.Time     `json:"until"`
+	Periods    []Period      `json:"periods"`
+	LeadTime   LeadTime      `json:"lead_time"`
+	Overdue    Overdue       `json:"overdue"`
+	Priorities []PriorityMix `json:"priorities"`
+}
+
+// Collect returns every task the data dir has known: active, archived and
+// trashed ones, and the ones deleted for good, recovered from the git
+// history and stamped with when they were deleted.
+func Collect(s *storage.Store) ([]*task.Task, error) {
+	active, err := s.List(false)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.List(true)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := s.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.Deleted()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := slices.Concat(active, archived, trashed)
+	for _, d := range deleted {
+		t := *d.Task
+		if t.DeletedAt == nil {
+			when := d.DeletedAt
+			t.DeletedAt = &when
+		}
+		tasks = append(tasks, &t)
+	}
+	return tasks, nil
+}
+
+// Compute works out the statistics for tasks as of now.
+func Compute(tasks []*task.Task, opts Options, now time.Time) Report {
+	start, step, by := startOfDay, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, "day"
+	since := opts.Since
+	if opts.Weekly {
+		start, step, by = startOfWeek, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, "week"
+		if since.IsZero() {
+			since = now.AddDate(0, 0, -7*(defaultWeeks-1))
+		}
+	} else if since.IsZero() {
+		since = now.AddDate(0, 0, -(defaultDays - 1))
+	}
+
+	r := Report{By: by, Since: start(since), Until: now}
+	for p := r.Since; !p.After(now); p = step(p) {
+		r.Periods = append(r.Periods, Period{Start: p})
+	}
+	// period returns the index of the period holding t, or -1.
+	period := func(t time.Time) int {
+		if t.Before(r.Since) || t.After(now) {
+			return -1
+		}
+		for i := len(r.Periods) - 1; i >= 0; i-- {
+			if !t.Before(r.Periods[i].Start) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	mix := map[task.Priority]*PriorityMix{}
+	for _, p := range []task.Priority{task.PriorityHigh, task.PriorityMedium, task.PriorityLow} {
+		r.Priorities = append(r.Priorities, PriorityMix{Priority: p})
+	}
+	for i := range r.Priorities {
+		mix[r.Priorities[i].Priority] = &r.Priorities[i]
+	}
+
+	var leads []float64
+	for _, t := range tasks {
+		if i := period(t.CreatedAt); i >= 0 {
+			r.Periods[i].Created++
+		}
+		for i := range r.Periods {
+			end := now
+			if i+1 < len(r.Periods) {
+				end = r.Periods[i+1].Start
+			}
+			if openAt(t, end) {
+				r.Periods[i].Open++
+			}
+		}
+
+		if t.CompletedAt != nil {
+			if i := period(*t.CompletedAt); i >= 0 {
+				r.Periods[i].Completed++
+				leads = append(leads, t.CompletedAt.Sub(t.CreatedAt).Hours()/24)
+				if m := mix[t.Priority]; m != nil {
+					m.Completed++
+				}
+			}
+		} else if t.DeletedAt == nil {
+			if m := mix[t.Priority]; m != nil {
+				m.Open++
+			}
+			if t.Deadline != nil && now.After(*t.Deadline) {
+				r.Overdue.Open++
+			}
+		}
+
+		if t.Deadline != nil && period(*t.Deadline) >= 0 && now.After(*t.Deadline) {
+			// Tasks deleted before their deadline never came due.
+			if t.CompletedAt == nil && t.DeletedAt != nil && !t.DeletedAt.After(*t.Deadline) {
+				continue
+			}
+			r.Overdue.Due++
+			if t.CompletedAt == nil || t.CompletedAt.After(*t.Deadline) {
+				r.Overdue.Late++
+			}
+		}
+	}
+	if r.Overdue.Due > 0 {
+		r.Overdue.Rate = float64(r.Overdue.Late) / float64(r.Overdue.Due)
+	}
+	r.LeadTime = leadTime(leads)
+	return r
+}
+
+// openAt reports whether t was open at the given time: created, and
+// neither completed nor deleted yet.
+func openAt(t *task.Task, at time.Time) bool {
+	if t.CreatedAt.After(at) {
+		return false
+	}
+	if t.CompletedAt != nil && !t.CompletedAt.After(at) {
+		return false
+	}
+	return t.DeletedAt == nil || t.DeletedAt.After(at)
+}
+
+// leadTimeBuckets are the upper bounds, in days, of the lead time
+// histogram's bars; the last bar takes the rest.
+var leadTimeBuckets = []struct {
+	label string
+	days  float64
+}{
+	{"< 1 day", 1},
+	{"1-3 days", 3},
+	{"3-7 days", 7},
+	{"1-4 weeks", 28},
+	{"> 4 weeks", math.Inf(1)},
+}
+
+func leadTime(days []float64) LeadTime {
+	lt := LeadTime{Count: len(days)}
+	for _, b := range leadTimeBuckets {
+		lt.Histogram = append(lt.Histogram, Bucket{Label: b.label})
+	}
+	if len(days) == 0 {
+		return lt
+	}
+	slices.Sort(days)
+	var sum float64
+	for _, d := range days {
+		sum += d
+		for i, b := range leadTimeBuckets {
+			if d < b.days {
+				lt.Histogram[i].Count++
+				break
+			}
+		}
+	}
+	lt.MeanDays = sum / float64(len(days))
+	lt.MedianDays = percentile(days, 0.5)
+	lt.P90Days = percentile(days, 0.9)
+	return lt
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating
+// between the two nearest ones.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// FormatDays renders a duration in days, in hours when under a day.
+func FormatDays(days float64) string {
+	if days < 1 {
+		return fmt.Sprintf("%.1fh", days*24)
+	}
+	return fmt.Sprintf("%.1fd", days)
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// startOfWeek returns the Monday that starts t's ISO week.
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/task"
+)
+
+// importTrailer marks import commits so they can be found and undone.
+const (
+	importTrailer = "Invar-Import:"
+	undoneTrailer = "Invar-Undo-Import:"
+)
+
+// ErrNoImport is returned by UndoImport when there is no import to undo.
+var ErrNoImport = errors.New("no import to undo")
+
+// SaveAll writes several tasks and records them in a single commit. The
+// hooks run for every task before any is written, so a hook refusing one
+// task stops them all.
+func (s *Store) SaveAll(tasks []*task.Task, message string) error {
+	// Loading every old task is only worth it if there are hooks at all;
+	// an empty event matches the hooks for every event.
+	scripts, err := s.hookScripts("")
+	if err != nil {
+		return err
+	}
+	if len(scripts) > 0 {
+		hooked := make([]*task.Task, len(tasks))
+		for i, t := range tasks {
+			old, err := s.Load(t.ID)
+			if err != nil {
+				old = nil
+			}
+			c := *t
+			if err := s.hookTask(old, &c); err != nil {
+				return err
+			}
+			hooked[i] = &c
+		}
+		// The tasks only take the hooks' changes once every hook agreed.
+		for i, t := range tasks {
+			*t = *hooked[i]
+		}
+	}
+
+	var paths []string
+	for _, t := range tasks {
+		from := locate(s.path(t.ID))
+		if err := s.move(from, s.path(t.ID), t); err != nil {
+			return err
+		}
+		paths = append(paths, from, s.path(t.ID))
+	}
+	if err := s.repo.CommitPaths(message, paths...); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		s.emit(EventSaved, t.ID, t)
+	}
+	return nil
+}
+
+// Import saves imported tasks as one commit tagged with the source, so the
+// whole import can be reverted with UndoImport.
+func (s *Store) Import(tasks []*task.Task, source string) error {
+	message := fmt.Sprintf("Import %d tasks from %s\n\n%s %s", len(tasks), source, importTrailer, source)
+	return s.SaveAll(tasks, message)
+}
+
+// UndoImport removes the tasks added by the most recent import that has
+// not been undone yet, as a new commit. Tasks from that import that have
+// since been deleted are skipped, and ones changed since are kept and
+// returned. It returns the number of tasks removed.
+func (s *Store) UndoImport() (int, []*task.Task, error) {
+	// Undo commits are always newer than the import they undo, so walking
+	// newest first sees the undo before the import.
+	undone := map[string]bool{}
+	hash, _, err := s.repo.FindCommit(func(hash, message string) bool {
+		if target := trailer(message, undoneTrailer); target != "" {
+			undone[target] = true
+			return false
+		}
+		return trailer(message, importTrailer) != "" && !undone[hash]
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if hash == "" {
+		return 0, nil, ErrNoImport
+	}
+
+	added, err := s.repo.AddedFiles(hash)
+	if err != nil {
+		return 0, nil, err
+	}
+	var ids, removed []string
+	var kept, purged []*task.Task
+	for _, p := range slices.Sorted(maps.Keys(added)) {
+		id, ok := taskID(p)
+		if !ok || strings.HasPrefix(p, trashDir+"/") {
+			continue
+		}
+		// The import may predate sharding, so look the task up by ID.
+		filename := locate(s.path(id))
+		data, err := os.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if git.HashBlob(data) != added[p] {
+			if t, err := s.decode(filename, data); err == nil {
+				kept = append(kept, t)
+			} else {
+				kept = append(kept, &task.Task{ID: id})
+			}
+			continue
+		}
+		t, err := s.decode(filename, data)
+		if err != nil {
+			t = &task.Task{ID: id}
+		}
+		ids = append(ids, id)
+		removed = append(removed, filename)
+		purged = append(purged, t)
+	}
+
+	if len(ids) == 0 {
+		if len(kept) > 0 {
+			return 0, kept, errors.New("every task left from the last import was changed since, so none were removed")
+		}
+		return 0, nil, errors.New("the tasks from the last import have already been removed")
+	}
+
+	if err := s.hookPurges(purged); err != nil {
+		return 0, nil, err
+	}
+	for _, filename := range removed {
+		if err := os.Remove(filename); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	message := fmt.Sprintf("Undo import of %d tasks\n\n%s %s", len(ids), undoneTrailer, hash)
+	if err := s.repo.CommitPaths(message, removed...); err != nil {
+		return 0, nil, err
+	}
+	for _, id := range ids {
+		s.emit(EventPurged, id, nil)
+	}
+	return len(ids), kept, nil
+}
+
+// trailer returns the value of a "Key: value" trailer line in a commit
+// message, or "" if it is absent.
+func trailer(message, key string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if value, ok := strings.CutPrefix(line, key); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
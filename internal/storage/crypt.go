@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/invar/internal/crypt"
+	"github.com/user/invar/internal/task"
+)
+
+// Encrypted reports whether the data dir stores its tasks encrypted.
+func (s *Store) Encrypted() bool {
+	return s.crypt != nil
+}
+
+// Locked reports whether the data dir is encrypted and no key is loaded.
+func (s *Store) Locked() bool {
+	return s.crypt != nil && s.key == nil
+}
+
+// Unlock derives the key from a passphrase or key file contents and checks
+// it against the data dir before using it.
+func (s *Store) Unlock(secret []byte) error {
+	if s.crypt == nil {
+		return nil
+	}
+	k, err := s.crypt.Derive(secret)
+	if err != nil {
+		return err
+	}
+	if err := s.crypt.Verify(k); err != nil {
+		return err
+	}
+	s.key = k
+	return nil
+}
+
+// CacheKey remembers the loaded key for the rest of the session, so later
+// invocations open the data dir without asking again.
+func (s *Store) CacheKey() error {
+	if s.key == nil {
+		return nil
+	}
+	return crypt.CacheKey(s.dataDir, s.key)
+}
+
+// ForgetKey drops the loaded key and removes it from the session cache.
+func (s *Store) ForgetKey() error {
+	if s.crypt != nil {
+		s.key = nil
+	}
+	return crypt.ForgetKey(s.dataDir)
+}
+
+// EnableEncryption rewrites every task file encrypted with a key derived
+// from secret. Earlier plaintext versions remain in the git history.
+func (s *Store) EnableEncryption(secret []byte) error {
+	if s.crypt != nil {
+		return errors.New("task data is already encrypted")
+	}
+	files, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	conflicts, err := s.Conflicts()
+	if err != nil {
+		return err
+	}
+
+	params, err := crypt.NewParams()
+	if err != nil {
+		return err
+	}
+	k, err := params.Derive(secret)
+	if err != nil {
+		return err
+	}
+	if err := params.SetCheck(k); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.dataDir, metaDir), 0755); err != nil {
+		return err
+	}
+	if err := params.Save(s.cryptPath()); err != nil {
+		return err
+	}
+	s.crypt, s.key = params, k
+
+	if err := s.writeAll(files); err != nil {
+		return err
+	}
+	if err := s.saveConflicts(conflicts); err != nil {
+		return err
+	}
+	return s.repo.Commit("Encrypt task files")
+}
+
+// DisableEncryption rewrites every task file in plaintext. The store must
+// be unlocked.
+func (s *Store) DisableEncryption() error {
+	if s.crypt == nil {
+		return errors.New("task data is not encrypted")
+	}
+	if s.key == nil {
+		return ErrLocked
+	}
+	files, err := s.readAll()
+	if err != nil {
+		return err
+	}
+	conflicts, err := s.Conflicts()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.cryptPath()); err != nil {
+		return err
+	}
+	crypt.ForgetKey(s.dataDir)
+	s.crypt, s.key = nil, nil
+
+	if err := s.writeAll(files); err != nil {
+		return err
+	}
+	if err := s.saveConflicts(conflicts); err != nil {
+		return err
+	}
+	return s.repo.Commit("Decrypt task files")
+}
+
+// readAll loads every active and trashed task file, keyed by path. Unlike
+// List it fails on the first unreadable file, so a rewrite never leaves a
+// file behind in the wrong form.
+func (s *Store) readAll() (map[string]*task.Task, error) {
+	files := map[string]*task.Task{}
+	for _, dir := range []string{s.dataDir, filepath.Join(s.dataDir, trashDir)} {
+		paths, err := s.taskFiles(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			t, err := s.read(path)
+			if err != nil {
+				return nil, err
+			}
+			files[path] = t
+		}
+	}
+	return files, nil
+}
+
+// writeAll rewrites the files readAll loaded in the current format and,
+// for the ones in the other format, under the current format's name. Where
+// a task has a file in both, the copy updated last is kept.
+func (s *Store) writeAll(files map[string]*task.Task) error {
+	targets := map[string]*task.Task{}
+	for path, t := range files {
+		target := strings.TrimSuffix(path, filepath.Ext(path)) + s.format.ext()
+		if prev := targets[target]; prev == nil || prev.UpdatedAt.Before(t.UpdatedAt) {
+			targets[target] = t
+		}
+	}
+	for target, t := range targets {
+		if err := s.write(target, t); err != nil {
+			return err
+		}
+	}
+	for path := range files {
+		if targets[path] != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/task"
+)
+
+// TestEncryptedHistory checks that the commits an encrypted store makes
+// don't give away what its tasks say.
+func TestEncryptedHistory(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnableEncryption([]byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	tk := task.New("Call the dentist")
+	if err := s.Save(tk); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Date(2026, 11, 2, 15, 0, 0, 0, time.UTC)
+	tk.SetDeadline(&deadline)
+	if err := s.Save(tk); err != nil {
+		t.Fatal(err)
+	}
+	tk.CyclePriority()
+	if err := s.Save(tk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(tk.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []string
+	err = s.repo.Walk(func(c git.Commit) bool {
+		log = append(log, c.Message)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(log, "\n")
+	for _, secret := range []string{"dentist", "2026-11-02", "15:00", "high", "low"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("git log contains %q:\n%s", secret, all)
+		}
+	}
+	if !strings.Contains(all, task.ShortID(tk.ID)) {
+		t.Errorf("git log does not name the task:\n%s", all)
+	}
+}
+
+// TestEncryptedConflicts checks that the conflicts an encrypted store
+// keeps for the user are sealed, and read back once unlocked.
+func TestEncryptedConflicts(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours, theirs := task.New("Call the dentist"), task.New("Call the doctor")
+	theirs.ID = ours.ID
+	c := Conflict{TaskID: ours.ID, OursTask: ours, TheirsTask: theirs}
+	c.Field, c.Ours, c.Theirs = "content", ours.Content, theirs.Content
+	if err := s.addConflicts([]Conflict{c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnableEncryption([]byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(s.conflictsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Call the") {
+		t.Errorf("conflicts file is readable:\n%s", data)
+	}
+	conflicts, err := s.Conflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].TheirsTask.Content != "Call the doctor" {
+		t.Errorf("Conflicts = %+v", conflicts)
+	}
+
+	s.key = nil
+	if _, err := s.Conflicts(); err != ErrLocked {
+		t.Errorf("Conflicts while locked = %v, want ErrLocked", err)
+	}
+}
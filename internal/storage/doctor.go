@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/user/invar/internal/task"
+)
+
+// quarantineDir holds files the doctor could not repair. They are kept in
+// the repo so nothing is lost, but are never read as tasks.
+const quarantineDir = "quarantine"
+
+// ProblemKind classifies an integrity problem found by Check.
+type ProblemKind string
+
+const (
+	ProblemUnreadable      ProblemKind = "unreadable"
+	ProblemWrongFormat     ProblemKind = "wrong-format"
+	ProblemIDMismatch      ProblemKind = "id-mismatch"
+	ProblemMisplaced       ProblemKind = "misplaced"
+	ProblemDuplicateID     ProblemKind = "duplicate-id"
+	ProblemInvalidPriority ProblemKind = "invalid-priority"
+	ProblemTrashState      ProblemKind = "trash-state"
+	ProblemDirtyWorktree   ProblemKind = "dirty-worktree"
+	ProblemHistory         ProblemKind = "history"
+)
+
+// Problem is a single integrity issue in the data dir or its git repo.
+// Path is relative to the data dir and empty for repo-wide problems.
+type Problem struct {
+	Kind    ProblemKind
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", p.Kind, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Kind, p.Path, p.Message)
+}
+
+// checkedFile is a task file as seen by the doctor.
+type checkedFile struct {
+	rel     string
+	trashed bool
+	task    *task.Task
+	err     error
+}
+
+// Check inspects every task file and the git repo and reports what is
+// wrong with them. It refuses to run on a locked store, where every file
+// would look unreadable.
+func (s *Store) Check() ([]Problem, error) {
+	if s.Locked() {
+		return nil, ErrLocked
+	}
+	files, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	seen := map[string][]string{}
+	for _, f := range files {
+		if f.err != nil {
+			problems = append(problems, Problem{ProblemUnreadable, f.rel, f.err.Error()})
+			continue
+		}
+		seen[f.task.ID] = append(seen[f.task.ID], f.rel)
+
+		ext := filepath.Ext(f.rel)
+		if ext != s.format.ext() {
+			problems = append(problems, Problem{ProblemWrongFormat, f.rel,
+				fmt.Sprintf("%s file in a %s data dir", strings.TrimPrefix(ext, "."), s.format)})
+		}
+		name := strings.TrimSuffix(filepath.Base(f.rel), ext)
+		if name != f.task.ID {
+			problems = append(problems, Problem{ProblemIDMismatch, f.rel,
+				fmt.Sprintf("file name does not match task ID %s", f.task.ID)})
+		}
+		dir := shard(name)
+		if f.trashed {
+			dir = filepath.Join(trashDir, dir)
+		}
+		if filepath.Dir(f.rel) != dir {
+			problems = append(problems, Problem{ProblemMisplaced, f.rel,
+				fmt.Sprintf("file belongs in %s", dir)})
+		}
+		switch f.task.Priority {
+		case task.PriorityHigh, task.PriorityMedium, task.PriorityLow:
+		default:
+			problems = append(problems, Problem{ProblemInvalidPriority, f.rel,
+				fmt.Sprintf("invalid priority %q", f.task.Priority)})
+		}
+		if f.trashed != (f.task.DeletedAt != nil) {
+			msg := "task in trash has no deletion time"
+			if !f.trashed {
+				msg = "active task is marked as deleted"
+			}
+			problems = append(problems, Problem{ProblemTrashState, f.rel, msg})
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if paths := seen[id]; len(paths) > 1 {
+			for _, rel := range paths[1:] {
+				problems = append(problems, Problem{ProblemDuplicateID, rel,
+					fmt.Sprintf("task ID %s is also used by %s", id, paths[0])})
+			}
+		}
+	}
+
+	if err := s.repo.Verify(); err != nil {
+		problems = append(problems, Problem{Kind: ProblemHistory, Message: err.Error()})
+	}
+	dirty, err := s.repo.Dirty()
+	if err != nil {
+		problems = append(problems, Problem{Kind: ProblemHistory, Message: err.Error()})
+	} else if len(dirty) > 0 {
+		problems = append(problems, Problem{Kind: ProblemDirtyWorktree,
+			Message: fmt.Sprintf("%d uncommitted changes (%s)", len(dirty), strings.Join(dirty, ", "))})
+	}
+	return problems, nil
+}
+
+// Repair fixes the given problems, moves unreadable files into the
+// quarantine dir and records everything in a single commit. It returns a
+// description of each action taken. History problems cannot be repaired
+// and are left alone.
+func (s *Store) Repair(problems []Problem) ([]string, error) {
+	if s.Locked() {
+		return nil, ErrLocked
+	}
+
+	var paths []string
+	kinds := map[string][]ProblemKind{}
+	for _, p := range problems {
+		if p.Path == "" {
+			continue
+		}
+		if kinds[p.Path] == nil {
+			paths = append(paths, p.Path)
+		}
+		kinds[p.Path] = append(kinds[p.Path], p.Kind)
+	}
+
+	var actions []string
+	for _, rel := range paths {
+		action, err := s.repairFile(rel, kinds[rel])
+		if err != nil {
+			return actions, fmt.Errorf("%s: %w", rel, err)
+		}
+		actions = append(actions, action)
+	}
+
+	for _, p := range problems {
+		if p.Kind == ProblemDirtyWorktree {
+			actions = append(actions, "committed uncommitted changes")
+		}
+	}
+	if len(actions) == 0 {
+		return nil, nil
+	}
+	return actions, s.repo.Commit(fmt.Sprintf("Doctor: %d repairs", len(actions)))
+}
+
+// repairFile fixes every problem with a single file at once: it is read,
+// normalised and written back under its canonical name.
+func (s *Store) repairFile(rel string, kinds []ProblemKind) (string, error) {
+	path := filepath.Join(s.dataDir, rel)
+	if slices.Contains(kinds, ProblemUnreadable) {
+		return s.quarantine(rel)
+	}
+
+	t, err := s.read(path)
+	if err != nil {
+		return s.quarantine(rel)
+	}
+
+	trashed := strings.HasPrefix(rel, trashDir+string(filepath.Separator))
+	if slices.Contains(kinds, ProblemDuplicateID) {
+		t.ID = uuid.New().String()
+	}
+	switch t.Priority {
+	case task.PriorityHigh, task.PriorityMedium, task.PriorityLow:
+	default:
+		t.Priority = task.PriorityMedium
+	}
+	if trashed && t.DeletedAt == nil {
+		t.DeletedAt = &t.UpdatedAt
+	}
+	if !trashed {
+		t.DeletedAt = nil
+	}
+
+	target := s.path(t.ID)
+	if trashed {
+		target = s.trashPath(t.ID)
+	}
+	if target != path {
+		if _, err := os.Stat(target); err == nil {
+			t.ID = uuid.New().String()
+			target = s.path(t.ID)
+			if trashed {
+				target = s.trashPath(t.ID)
+			}
+		}
+	}
+	if err := s.write(target, t); err != nil {
+		return "", err
+	}
+	if target != path {
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+	}
+
+	newRel, _ := filepath.Rel(s.dataDir, target)
+	if newRel != rel {
+		return fmt.Sprintf("repaired %s as %s", rel, newRel), nil
+	}
+	return "repaired " + rel, nil
+}
+
+func (s *Store) quarantine(rel string) (string, error) {
+	dir := filepath.Join(s.dataDir, quarantineDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := strings.ReplaceAll(rel, string(filepath.Separator), "_")
+	if err := os.Rename(filepath.Join(s.dataDir, rel), filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("quarantined %s as %s/%s", rel, quarantineDir, name), nil
+}
+
+// scan reads every JSON or Markdown file in the data dir and the trash,
+// keeping read errors instead of skipping the file like List does. Files
+// outside the shards are included so Check can report them.
+func (s *Store) scan() ([]checkedFile, error) {
+	var files []checkedFile
+	for _, dir := range []string{"", trashDir} {
+		entries, err := os.ReadDir(filepath.Join(s.dataDir, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rels []string
+		for _, entry := range entries {
+			if isShard(entry) {
+				sub, err := os.ReadDir(filepath.Join(s.dataDir, dir, entry.Name()))
+				if err != nil {
+					return nil, err
+				}
+				for _, e := range sub {
+					rels = append(rels, filepath.Join(dir, entry.Name(), e.Name()))
+				}
+			} else if !entry.IsDir() {
+				rels = append(rels, filepath.Join(dir, entry.Name()))
+			}
+		}
+		for _, rel := range rels {
+			ext := filepath.Ext(rel)
+			if (ext != ".json" && ext != ".md") || !validID(sealName(rel)) {
+				continue
+			}
+			// Problems carry the path already, so decoding errors leave
+			// it out; read errors keep the operation that failed.
+			full := filepath.Join(s.dataDir, rel)
+			var t *task.Task
+			data, err := os.ReadFile(full)
+			if err == nil {
+				t, err = s.parse(full, data)
+			}
+			files = append(files, checkedFile{rel: rel, trashed: dir == trashDir, task: t, err: err})
+		}
+	}
+	return files, nil
+}
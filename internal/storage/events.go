@@ -0,0 +1,44 @@
+package storage
+
+import "github.com/user/invar/internal/task"
+
+// EventType says what happened to a task.
+type EventType int
+
+const (
+	// EventSaved is sent when a task is created or updated.
+	EventSaved EventType = iota
+	// EventTrashed is sent when a task is moved to the trash.
+	EventTrashed
+	// EventRestored is sent when a task is moved out of the trash.
+	EventRestored
+	// EventPurged is sent when a task is removed for good. Task is nil.
+	EventPurged
+	// EventSynced is sent when a sync brought in changes from the remote.
+	// ID and Task are empty; any task may have changed.
+	EventSynced
+	// EventSwitched is sent when another branch was checked out, such as a
+	// planning branch. ID and Task are empty; any task may have changed.
+	EventSwitched
+)
+
+// Event describes a change made through the store. Task holds the task as
+// it was written.
+type Event struct {
+	Type EventType
+	ID   string
+	Task *task.Task
+}
+
+// Subscribe registers fn to be called after every change made through the
+// store. Changes made behind the store's back, such as hand edits, are not
+// reported.
+func (s *Store) Subscribe(fn func(Event)) {
+	s.subscribers = append(s.subscribers, fn)
+}
+
+func (s *Store) emit(typ EventType, id string, t *task.Task) {
+	for _, fn := range s.subscribers {
+		fn(Event{Type: typ, ID: id, Task: t})
+	}
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/invar/internal/task"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the on-disk encoding of task files. It is chosen per data dir
+// and recorded in the data dir's settings.
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+)
+
+// ParseFormat accepts a format name as typed on the command line.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return FormatJSON, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want json or markdown)", name)
+}
+
+func (f Format) ext() string {
+	if f == FormatMarkdown {
+		return ".md"
+	}
+	return ".json"
+}
+
+// formatForFile picks the decoder from the file extension, so files can be
+// read regardless of the data dir's current format.
+func formatForFile(filename string) Format {
+	if filepath.Ext(filename) == ".md" {
+		return FormatMarkdown
+	}
+	return FormatJSON
+}
+
+// settings are per-data-dir options that travel with the repo.
+type settings struct {
+	Format Format `json:"format,omitempty"`
+}
+
+func (s *Store) settingsPath() string {
+	return filepath.Join(s.dataDir, metaDir, "settings.json")
+}
+
+func (s *Store) loadSettings() error {
+	data, err := os.ReadFile(s.settingsPath())
+	if os.IsNotExist(err) {
+		s.format = FormatJSON
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st settings
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("%s: %w", s.settingsPath(), err)
+	}
+	s.format = st.Format
+	if s.format == "" {
+		s.format = FormatJSON
+	}
+	return nil
+}
+
+func (s *Store) saveSettings() error {
+	if err := os.MkdirAll(filepath.Join(s.dataDir, metaDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings{Format: s.format}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.settingsPath(), data, 0644)
+}
+
+// Format returns the file format of the data dir.
+func (s *Store) Format() Format {
+	return s.format
+}
+
+// SetFormat converts every task file to the given format and records the
+// choice in a single commit.
+func (s *Store) SetFormat(f Format) error {
+	if f == s.format {
+		return nil
+	}
+	files, err := s.readAll()
+	if err != nil {
+		return err
+	}
+
+	s.format = f
+	if err := s.writeAll(files); err != nil {
+		return err
+	}
+	if err := s.saveSettings(); err != nil {
+		return err
+	}
+	return s.repo.Commit(fmt.Sprintf("Convert task files to %s", f))
+}
+
+func encodeTask(f Format, t *task.Task) ([]byte, error) {
+	if f == FormatMarkdown {
+		return encodeMarkdown(t)
+	}
+	return json.MarshalIndent(t, "", "  ")
+}
+
+func decodeTask(f Format, data []byte) (*task.Task, error) {
+	if f == FormatMarkdown {
+		return decodeMarkdown(data)
+	}
+	var t task.Task
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+const frontmatterDelim = "---"
+
+// encodeMarkdown writes the task metadata as YAML frontmatter followed by
+// the content as the Markdown body.
+func encodeMarkdown(t *task.Task) ([]byte, error) {
+	meta, err := yaml.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(frontmatterDelim + "\n")
+	buf.Write(meta)
+	buf.WriteString(frontmatterDelim + "\n\n")
+	buf.WriteString(t.Content)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// decodeMarkdown parses a task file written by encodeMarkdown or edited by
+// hand. It tolerates CRLF line endings, a missing blank line after the
+// frontmatter, missing optional fields and differently cased priorities.
+func decodeMarkdown(data []byte) (*task.Task, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\ufeff")
+
+	lines := strings.Split(text, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != frontmatterDelim {
+		return nil, errors.New("line 1: expected '---' to open the frontmatter")
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == frontmatterDelim {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, errors.New("frontmatter is not closed by a '---' line")
+	}
+
+	// Keep the opening delimiter as a blank line so YAML error line numbers
+	// match the file.
+	meta := "\n" + strings.Join(lines[1:end], "\n")
+	var t task.Task
+	if err := yaml.Unmarshal([]byte(meta), &t); err != nil {
+		return nil, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+
+	t.Priority = task.Priority(strings.ToLower(strings.TrimSpace(string(t.Priority))))
+	switch t.Priority {
+	case task.PriorityHigh, task.PriorityMedium, task.PriorityLow:
+	case "":
+		t.Priority = task.PriorityMedium
+	default:
+		return nil, fmt.Errorf("invalid priority %q (want high, medium or low)", t.Priority)
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+
+	// Strip the blank line after the frontmatter and the newline that ends
+	// the file, and nothing else, so content keeps its own blank lines.
+	body := strings.Join(lines[end+1:], "\n")
+	body = strings.TrimPrefix(body, "\n")
+	t.Content = strings.TrimSuffix(body, "\n")
+	return &t, nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	deadline := time.Date(2026, 11, 2, 15, 0, 0, 0, time.UTC)
+	for _, content := range []string{
+		"",
+		"Buy milk",
+		"Buy milk\n",
+		"Buy milk\n\n",
+		"\nStarts with a blank line",
+		"Title\n\nBody with a blank line\n",
+		"---\nLooks like frontmatter\n---",
+	} {
+		in := task.New(content)
+		in.Deadline = &deadline
+		in.Tags = []string{"home", "errand"}
+		in.AddComment("noted")
+
+		data, err := encodeMarkdown(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := decodeMarkdown(data)
+		if err != nil {
+			t.Fatalf("%q: %v", content, err)
+		}
+		if out.Content != in.Content {
+			t.Errorf("content %q came back as %q", in.Content, out.Content)
+		}
+		if out.ID != in.ID || out.Priority != in.Priority || !reflect.DeepEqual(out.Tags, in.Tags) {
+			t.Errorf("%q: metadata changed: %+v", content, out)
+		}
+		if out.Deadline == nil || !out.Deadline.Equal(deadline) || !out.CreatedAt.Equal(in.CreatedAt) {
+			t.Errorf("%q: times changed: %+v", content, out)
+		}
+		if len(out.Comments) != 1 || out.Comments[0].Text != "noted" {
+			t.Errorf("%q: comments changed: %+v", content, out.Comments)
+		}
+	}
+}
+
+// TestInterruptedFormatChange checks that a task left in the old format by
+// a conversion that was cut short stays visible, and moves to the current
+// format when it is next saved.
+func TestInterruptedFormatChange(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, converted := task.New("left behind"), task.New("converted")
+	if err := s.SaveAll([]*task.Task{left, converted}, "Seed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetFormat(FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	old := otherFormat(s.path(left.ID))
+	if err := os.Rename(s.path(left.ID), old); err != nil {
+		t.Fatal(err)
+	}
+	data, err := encodeTask(FormatJSON, left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(old, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := s.List(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("listed %d tasks, want 2", len(tasks))
+	}
+	got, err := s.Load(left.ID)
+	if err != nil || got.Content != "left behind" {
+		t.Fatalf("Load = %v, %v", got, err)
+	}
+
+	got.Content = "saved again"
+	if err := s.Save(got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after saving", filepath.Base(old))
+	}
+	if got, err := s.read(s.path(left.ID)); err != nil || got.Content != "saved again" {
+		t.Errorf("read = %v, %v", got, err)
+	}
+	if dirty, err := s.repo.Dirty(); err != nil || len(dirty) > 0 {
+		t.Errorf("Dirty = %v, %v", dirty, err)
+	}
+}
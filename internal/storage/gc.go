@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/invar/internal/git"
+)
+
+// ErrHasRemote is returned by GC when it would rewrite history that is
+// shared with a remote.
+var ErrHasRemote = errors.New("the data dir syncs with a remote; rewriting its history would break other clones")
+
+// ErrHasPlans is returned by GC when squashing would cut planning branches
+// off the history they share with the main branch.
+var ErrHasPlans = errors.New("merge or discard the planning branches before squashing history")
+
+// GCOptions controls GC. Commits made before Before are squashed into one
+// commit per day, or per week with Weekly; a zero Before only repacks.
+// Force allows squashing when a remote is configured: the data dir is
+// synced first and the squashed history then replaces the remote's.
+type GCOptions struct {
+	Before time.Time
+	Weekly bool
+	Force  bool
+}
+
+// GCReport says what GC did and how much space the repo's objects took
+// before and after. Pulled is set when the sync before a forced squash
+// pulled changes, and Replaced when the remote's history was replaced.
+type GCReport struct {
+	Squashed   int
+	Into       int
+	Pulled     bool
+	Replaced   bool
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// GC compacts the repo: it squashes old history as the options say, then
+// deletes unreachable objects and packs the rest. After a pull, the caller
+// runs Reload as it would after Sync.
+func (s *Store) GC(ctx context.Context, opts GCOptions) (GCReport, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	var report GCReport
+	var url string
+	if !opts.Before.IsZero() {
+		var err error
+		if url, err = s.repo.Remote(); err != nil {
+			return report, err
+		}
+		if url != "" && !opts.Force {
+			return report, ErrHasRemote
+		}
+		plans, err := s.Plans()
+		if err != nil {
+			return report, err
+		}
+		if len(plans) > 0 {
+			return report, ErrHasPlans
+		}
+	}
+
+	var err error
+	if report.SizeBefore, err = s.repo.Size(); err != nil {
+		return report, err
+	}
+	if err := s.repo.Commit("Commit local changes"); err != nil {
+		return report, err
+	}
+	if !opts.Before.IsZero() {
+		if url != "" {
+			// The remote must not have commits the squash would leave out.
+			result, err := s.sync(ctx)
+			if err != nil {
+				return report, fmt.Errorf("sync before squashing: %w", err)
+			}
+			report.Pulled = result.Pulled
+		}
+		old, err := s.repo.Head()
+		if err != nil {
+			return report, err
+		}
+		bucket := git.Day
+		if opts.Weekly {
+			bucket = git.Week
+		}
+		if report.Squashed, report.Into, err = s.repo.Squash(opts.Before, bucket); err != nil {
+			return report, err
+		}
+		if url != "" && report.Squashed > 0 {
+			if err := s.repo.Replace(ctx, old); err != nil {
+				return report, fmt.Errorf("replace the remote's history: %w", err)
+			}
+			report.Replaced = true
+		}
+	}
+	if err := s.repo.Repack(); err != nil {
+		return report, err
+	}
+	report.SizeAfter, err = s.repo.Size()
+	return report, err
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/user/invar/internal/task"
+)
+
+// TestForcedGC squashes the history of a data dir that syncs with a remote
+// and checks that the remote gets the new history, that changes only the
+// remote had are kept, and that the old history is gone.
+func TestForcedGC(t *testing.T) {
+	ctx := context.Background()
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	a, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetRemote(remote); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := a.Save(task.New(fmt.Sprintf("task %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	old, err := a.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetRemote(remote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(task.New("only on the remote")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := GCOptions{Before: time.Now().Add(time.Minute)}
+	if _, err := a.GC(ctx, opts); err != ErrHasRemote {
+		t.Fatalf("GC without Force = %v, want ErrHasRemote", err)
+	}
+	opts.Force = true
+	report, err := a.GC(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Pulled || !report.Replaced || report.Squashed == 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	tasks, err := a.List(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 6 {
+		t.Errorf("listed %d tasks after GC, want 6", len(tasks))
+	}
+
+	result, err := a.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pulled || result.Pushed {
+		t.Errorf("sync after GC = %s, want already up to date", result)
+	}
+	repo, err := gogit.PlainOpen(a.dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CommitObject(plumbing.NewHash(old)); err != plumbing.ErrObjectNotFound {
+		t.Errorf("old head is still there after GC: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// DeletedTask is the last committed version of a task that no longer
+// exists in the data dir or the trash.
+type DeletedTask struct {
+	Task      *task.Task
+	DeletedAt time.Time
+	Commit    string
+}
+
+// Deleted returns the tasks that were removed from the repo, newest
+// deletion first, recovered from the git history.
+func (s *Store) Deleted() ([]DeletedTask, error) {
+	present := map[string]bool{}
+	for _, dir := range []string{s.dataDir, filepath.Join(s.dataDir, trashDir)} {
+		tasks, err := s.listDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, t := range tasks {
+			present[t.ID] = true
+		}
+	}
+
+	removals, err := s.repo.Removals()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []DeletedTask
+	seen := map[string]bool{}
+	for _, r := range removals {
+		id, ok := taskID(r.Path)
+		if !ok || present[id] || seen[id] {
+			continue
+		}
+		t, err := s.decode(r.Path, r.Content)
+		if err != nil {
+			continue
+		}
+		seen[id] = true
+		deleted = append(deleted, DeletedTask{Task: t, DeletedAt: r.When, Commit: r.Hash})
+	}
+	return deleted, nil
+}
+
+// TaskVersion is a task as one commit left it. Task is nil for the commit
+// that deleted it for good. Changes lists the fields that differ from the
+// version before.
+type TaskVersion struct {
+	Task    *task.Task
+	Commit  string
+	When    time.Time
+	Subject string
+	Trashed bool
+	Changes []task.Change
+}
+
+// History returns every committed version of a task, newest first,
+// including the ones from before it was trashed or deleted.
+func (s *Store) History(id string) ([]TaskVersion, error) {
+	found, err := s.repo.FileHistory(taskPaths(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []TaskVersion
+	for _, f := range found {
+		subject, _, _ := strings.Cut(f.Message, "\n")
+		v := TaskVersion{
+			Commit:  f.Hash,
+			When:    f.When,
+			Subject: subject,
+			Trashed: strings.HasPrefix(f.Path, trashDir+"/"),
+		}
+		if f.Content != nil {
+			if v.Task, err = s.decode(f.Path, f.Content); err != nil {
+				// Versions we cannot read, such as ones encrypted with a
+				// different key, are left out.
+				continue
+			}
+		}
+		versions = append(versions, v)
+	}
+
+	for i := range versions {
+		if i+1 < len(versions) && versions[i].Task != nil && versions[i+1].Task != nil {
+			versions[i].Changes = task.Diff(versions[i+1].Task, versions[i].Task)
+		}
+	}
+	return versions, nil
+}
+
+// RestoreVersion makes the task from an earlier version the current one,
+// as a new commit. The task comes back as an active task even if it has
+// since been trashed or deleted.
+func (s *Store) RestoreVersion(v TaskVersion) error {
+	if v.Task == nil {
+		return errors.New("this version is the deletion of the task")
+	}
+	old, err := s.Load(v.Task.ID)
+	if err != nil {
+		old, _ = s.LoadTrashed(v.Task.ID)
+	}
+
+	t := *v.Task
+	t.DeletedAt = nil
+	t.UpdatedAt = time.Now()
+	if err := s.hookTask(old, &t); err != nil {
+		return err
+	}
+	from, trashed := locate(s.path(t.ID)), locate(s.trashPath(t.ID))
+	if err := s.move(from, s.path(t.ID), &t); err != nil {
+		return err
+	}
+	if err := os.Remove(trashed); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var changes []task.Change
+	if old != nil {
+		changes = task.Diff(old, &t)
+	}
+	message := s.taskMessage("Restore version from "+v.When.Local().Format("2006-01-02 15:04"), &t, changes)
+	message += fmt.Sprintf("\n%s %s", restoredTrailer, v.Commit)
+	if err := s.repo.CommitPaths(message, from, s.path(t.ID), trashed); err != nil {
+		return err
+	}
+	s.emit(EventSaved, t.ID, &t)
+	return nil
+}
+
+// Resurrect brings a task that was deleted from the repo back as an active
+// task, as a new commit. The id must be the task's full ID.
+func (s *Store) Resurrect(id string) (*task.Task, error) {
+	deleted, err := s.Deleted()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deleted {
+		if d.Task.ID != id {
+			continue
+		}
+		t := d.Task
+		t.DeletedAt = nil
+		t.UpdatedAt = time.Now()
+		if err := s.hookTask(nil, t); err != nil {
+			return nil, err
+		}
+		if err := s.write(s.path(t.ID), t); err != nil {
+			return nil, err
+		}
+		message := s.taskMessage("Resurrect", t, nil)
+		message += fmt.Sprintf("\n%s %s", resurrectedTrailer, d.Commit)
+		if err := s.repo.CommitPaths(message, s.path(t.ID)); err != nil {
+			return nil, err
+		}
+		s.emit(EventSaved, t.ID, t)
+		return t, nil
+	}
+	return nil, fmt.Errorf("task %s is not among the deleted tasks", id)
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// HookEvent names a change to a task that hooks run on. A hook is an
+// executable in the hooks dir whose name starts with "on-" and the event,
+// such as on-add or on-complete-notify.
+type HookEvent string
+
+const (
+	HookAdd      HookEvent = "add"
+	HookModify   HookEvent = "modify"
+	HookComplete HookEvent = "complete"
+	HookDelete   HookEvent = "delete"
+	HookArchive  HookEvent = "archive"
+)
+
+// DefaultHookTimeout is how long a hook may run when Hooks.Timeout is 0.
+const DefaultHookTimeout = 10 * time.Second
+
+// Hooks says where the hook scripts live and how long each may run. An
+// empty Dir turns hooks off.
+type Hooks struct {
+	Dir     string
+	Timeout time.Duration
+}
+
+// HookVeto is returned when a hook refuses a change by exiting with a
+// non-zero status. Message is what the hook printed.
+type HookVeto struct {
+	Hook    string
+	Message string
+}
+
+func (v *HookVeto) Error() string {
+	return fmt.Sprintf("hook %s refused the change: %s", v.Hook, v.Message)
+}
+
+// HookError is returned when a hook could not be run, timed out, or
+// printed something that is not a task. The change is not made.
+type HookError struct {
+	Hook string
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s failed: %v", e.Hook, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// SetHooks sets the hooks run on changes made from then on.
+func (s *Store) SetHooks(h Hooks) {
+	s.hooks = h
+}
+
+// Hooks returns the settings last passed to SetHooks.
+func (s *Store) Hooks() Hooks {
+	return s.hooks
+}
+
+// hookEvent says which event saving t over old is. Completing and
+// archiving count as their own events rather than as modify.
+func hookEvent(old, t *task.Task) HookEvent {
+	switch {
+	case old == nil:
+		return HookAdd
+	case old.CompletedAt == nil && t.CompletedAt != nil:
+		return HookComplete
+	case !old.Archived && t.Archived:
+		return HookArchive
+	}
+	return HookModify
+}
+
+// hookScripts returns the executables that run on event, in name order.
+func (s *Store) hookScripts(event HookEvent) ([]string, error) {
+	if s.hooks.Dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(s.hooks.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "on-"+string(event)) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		scripts = append(scripts, filepath.Join(s.hooks.Dir, entry.Name()))
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// hookTask runs the hooks for saving t over old, which is nil for a new
+// task, and applies any change they make to t.
+func (s *Store) hookTask(old, t *task.Task) error {
+	hooked, err := s.runHooks(hookEvent(old, t), old, t)
+	if err != nil {
+		return err
+	}
+	*t = *hooked
+	return nil
+}
+
+// hookPurges runs the delete hooks for removing tasks for good, before any
+// is removed, so a hook refusing one task stops them all.
+func (s *Store) hookPurges(tasks []*task.Task) error {
+	for _, t := range tasks {
+		if _, err := s.runHooks(HookDelete, t, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHooks passes a change from old to t through the hooks for event, each
+// one getting the task the previous one returned, and returns the task to
+// write. Delete hooks can only refuse the change; t is nil when the task is
+// removed for good.
+func (s *Store) runHooks(event HookEvent, old, t *task.Task) (*task.Task, error) {
+	scripts, err := s.hookScripts(event)
+	if err != nil || len(scripts) == 0 {
+		return t, err
+	}
+	for _, script := range scripts {
+		changed, err := s.runHook(script, event, old, t)
+		if err != nil {
+			return nil, err
+		}
+		if changed != nil && event != HookDelete {
+			t = changed
+		}
+	}
+	return t, nil
+}
+
+// runHook runs one hook with the old and new task as JSON lines on stdin,
+// "null" standing in for a missing one. A hook that exits cleanly may
+// print the task as JSON to change it; it is returned, or nil if the hook
+// printed nothing.
+func (s *Store) runHook(script string, event HookEvent, old, t *task.Task) (*task.Task, error) {
+	name := filepath.Base(script)
+	var stdin bytes.Buffer
+	for _, v := range []*task.Task{old, t} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, &HookError{Hook: name, Err: err}
+		}
+		stdin.Write(data)
+		stdin.WriteByte('\n')
+	}
+
+	timeout := s.hooks.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Dir = s.dataDir
+	cmd.Env = append(os.Environ(), "INVAR_EVENT="+string(event), "INVAR_DATA_DIR="+s.dataDir)
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait for children the hook left running with our pipes.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, &HookError{Hook: name, Err: fmt.Errorf("timed out after %s", timeout)}
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		message := strings.TrimSpace(stdout.String())
+		if message == "" {
+			message = strings.TrimSpace(stderr.String())
+		}
+		if message == "" {
+			message = exit.String()
+		}
+		return nil, &HookVeto{Hook: name, Message: message}
+	}
+	if err != nil {
+		return nil, &HookError{Hook: name, Err: err}
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return nil, nil
+	}
+	var changed task.Task
+	if err := json.Unmarshal(out, &changed); err != nil {
+		return nil, &HookError{Hook: name, Err: fmt.Errorf("invalid task JSON: %w", err)}
+	}
+	if t != nil && changed.ID != t.ID {
+		return nil, &HookError{Hook: name, Err: fmt.Errorf("changed the task ID to %q", changed.ID)}
+	}
+	return &changed, nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// writeHook puts an executable shell script named name in dir.
+func writeHook(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHooksRefuseBatch checks that a hook refusing one task of a batch
+// stops the whole batch and leaves the other tasks as they were.
+func TestHooksRefuseBatch(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := t.TempDir()
+	s.SetHooks(Hooks{Dir: hooks})
+	// Tags every task and refuses the one titled "refused".
+	writeHook(t, hooks, "on-add", `read old; read new
+case "$new" in *'"content":"refused"'*) echo no; exit 1;; esac
+echo "$new" | sed 's/"tags":\[\]/"tags":["hooked"]/'
+`)
+	// Refuses to remove the task titled "keep" for good.
+	writeHook(t, hooks, "on-delete", `read old; read new
+[ "$new" = null ] || exit 0
+case "$old" in *'"content":"keep"'*) echo no; exit 1;; esac
+`)
+
+	first, refused := task.New("first"), task.New("refused")
+	var veto *HookVeto
+	if err := s.SaveAll([]*task.Task{first, refused}, "Add"); !errors.As(err, &veto) {
+		t.Fatalf("SaveAll = %v, want a veto", err)
+	}
+	if len(first.Tags) != 0 {
+		t.Errorf("refused SaveAll changed a task: %v", first.Tags)
+	}
+	if err := s.SaveAll([]*task.Task{first}, "Add"); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Tags) != 1 || first.Tags[0] != "hooked" {
+		t.Errorf("tags after SaveAll = %v", first.Tags)
+	}
+
+	keep := task.New("keep")
+	if err := s.Save(keep); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{first.ID, keep.ID} {
+		if err := s.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.EmptyTrash(time.Now().Add(time.Minute)); !errors.As(err, &veto) {
+		t.Fatalf("EmptyTrash = %v, want a veto", err)
+	}
+	trashed, err := s.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 2 {
+		t.Errorf("%d tasks left in the trash, want 2", len(trashed))
+	}
+	if err := s.Purge(keep.ID); !errors.As(err, &veto) {
+		t.Errorf("Purge = %v, want a veto", err)
+	}
+	if err := s.Purge(first.ID); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestHooksOnUndo checks that undoing a change runs the hooks for the task
+// it puts back, and that other uncommitted files do not stop it.
+func TestHooksOnUndo(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first commit has no parent to revert to.
+	if err := s.Save(task.New("first")); err != nil {
+		t.Fatal(err)
+	}
+	keep := task.New("keep")
+	if err := s.Save(keep); err != nil {
+		t.Fatal(err)
+	}
+	keep.CyclePriority()
+	if err := s.Save(keep); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.DataDir(), "notes.txt"), []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hooks := t.TempDir()
+	s.SetHooks(Hooks{Dir: hooks})
+	writeHook(t, hooks, "on-modify", `read old; read new
+echo "$new" | sed 's/"tags":\[\]/"tags":["hooked"]/'
+`)
+	writeHook(t, hooks, "on-delete", `read old; read new
+[ "$new" = null ] || exit 0
+echo no; exit 1
+`)
+
+	if _, err := s.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Load(keep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Priority == keep.Priority || len(got.Tags) != 1 || got.Tags[0] != "hooked" {
+		t.Errorf("after undo = priority %v, tags %v; want the old priority, tagged", got.Priority, got.Tags)
+	}
+
+	var veto *HookVeto
+	if _, err := s.Undo(); !errors.As(err, &veto) {
+		t.Fatalf("undoing the add = %v, want a veto", err)
+	}
+	if _, err := s.Load(keep.ID); err != nil {
+		t.Errorf("refused undo removed the task: %v", err)
+	}
+}
@@ -1,21 +1,74 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/user/invar/internal/crypt"
 	"github.com/user/invar/internal/git"
 	"github.com/user/invar/internal/task"
 )
 
+// trashDir is the subdirectory of the data dir that holds deleted tasks
+// until they are restored or purged.
+const trashDir = "trash"
+
+// metaDir holds per-data-dir settings that travel with the repo.
+const metaDir = ".invar"
+
+// ErrLocked is returned when reading or writing tasks in an encrypted data
+// dir before Unlock has been called.
+var ErrLocked = errors.New("task data is encrypted; unlock it first")
+
+// errTampered is returned for an encrypted task file that does not open
+// with the data dir's key: it was altered, or copied from another task.
+var errTampered = errors.New("encrypted file was altered or belongs to another task")
+
 type Store struct {
 	dataDir string
 	repo    *git.Repo
+	format  Format
+	crypt   *crypt.Params
+	key     *crypt.Key
+	signing Signing
+	keyring *git.Keyring
+	hooks   Hooks
+
+	// syncMu keeps a sync started in the background from overlapping
+	// the one run on exit, and from running while branches are switched.
+	// Stores made by Reopen share it.
+	syncMu *sync.Mutex
+
+	subscribers []func(Event)
 }
 
+// New opens the data dir, creating it if needed, and tidies up after older
+// versions and processes that did not exit cleanly.
 func New(dataDir string) (*Store, error) {
+	s, err := Open(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	// A process that exited without flushing leaves the index behind.
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := s.moveToShards(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open opens the data dir like New but leaves its files and index alone, for
+// use while plain git is working on it, such as from the merge driver.
+func Open(dataDir string) (*Store, error) {
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, err
 	}
@@ -25,70 +78,477 @@ func New(dataDir string) (*Store, error) {
 		return nil, err
 	}
 
-	return &Store{dataDir: dataDir, repo: repo}, nil
+	s := &Store{dataDir: dataDir, repo: repo, syncMu: &sync.Mutex{}}
+	if err := s.loadSettings(); err != nil {
+		return nil, err
+	}
+	params, err := crypt.LoadParams(s.cryptPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if params != nil {
+		s.crypt = params
+		if k := crypt.CachedKey(dataDir); k != nil && params.Verify(k) == nil {
+			s.key = k
+		}
+	}
+	return s, nil
+}
+
+// Reopen returns another store on the same data dir, unlocked and signing
+// like s, for work done in the background. The two share no git state, so
+// the copy can work while s is used, the way another invar process can.
+func (s *Store) Reopen() (*Store, error) {
+	c, err := Open(s.dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if c.crypt != nil && s.key != nil && c.crypt.Verify(s.key) == nil {
+		c.key = s.key
+	}
+	if err := c.SetSigning(s.signing); err != nil {
+		return nil, err
+	}
+	c.syncMu = s.syncMu
+	return c, nil
+}
+
+func (s *Store) cryptPath() string {
+	return filepath.Join(s.dataDir, metaDir, "crypt.json")
+}
+
+// shard returns the subdirectory a task's file is kept in: the first two
+// characters of its ID. Spreading the files out keeps every git tree
+// small, so a commit costs about the same however many tasks there are.
+func shard(id string) string {
+	return (id + "__")[:2]
+}
+
+// isShard reports whether a directory entry is a shard, as opposed to the
+// trash or one of the data dir's other subdirectories.
+func isShard(entry os.DirEntry) bool {
+	return entry.IsDir() && len(entry.Name()) == 2 && entry.Name()[0] != '.'
+}
+
+// moveToShards moves task files left directly in the data dir or the
+// trash, by an older version or a device still running one, into their
+// shards as one commit. If the shard already has the task, the copy updated
+// last wins; when either cannot be read, the file is left for the doctor.
+// It reports whether anything moved.
+func (s *Store) moveToShards() (bool, error) {
+	var paths []string
+	for _, dir := range []string{s.dataDir, filepath.Join(s.dataDir, trashDir)} {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			if !s.isTaskFile(entry) {
+				continue
+			}
+			from := filepath.Join(dir, entry.Name())
+			to := filepath.Join(dir, shard(sealName(from)), entry.Name())
+			if _, err := os.Stat(to); err == nil {
+				flat, ferr := s.read(from)
+				sharded, serr := s.read(to)
+				if ferr != nil || serr != nil {
+					continue
+				}
+				if sharded.UpdatedAt.Before(flat.UpdatedAt) {
+					err = os.Rename(from, to)
+				} else {
+					err = os.Remove(from)
+				}
+				if err != nil {
+					return false, err
+				}
+			} else {
+				if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+					return false, err
+				}
+				if err := os.Rename(from, to); err != nil {
+					return false, err
+				}
+			}
+			paths = append(paths, from, to)
+		}
+	}
+	if len(paths) == 0 {
+		return false, nil
+	}
+	return true, s.repo.CommitPaths(fmt.Sprintf("Move %d task files into shards", len(paths)/2), paths...)
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dataDir, shard(id), id+s.format.ext())
+}
+
+func (s *Store) trashPath(id string) string {
+	return filepath.Join(s.dataDir, trashDir, shard(id), id+s.format.ext())
+}
+
+// isTaskFile reports whether a directory entry is a task file in either
+// format. A conversion to another format that was cut short leaves some in
+// the old one, and those are read until they are next written. Other files
+// a user keeps in the data dir, such as a README.md, are not tasks.
+func (s *Store) isTaskFile(entry os.DirEntry) bool {
+	ext := filepath.Ext(entry.Name())
+	return !entry.IsDir() && (ext == ".json" || ext == ".md") && validID(sealName(entry.Name()))
+}
+
+// validID reports whether id has the form of a task ID, a UUID in its
+// canonical form.
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil && len(id) == 36
+}
+
+// otherFormat returns the name a task file would have in the format it is
+// not in.
+func otherFormat(filename string) string {
+	ext := ".md"
+	if filepath.Ext(filename) == ".md" {
+		ext = ".json"
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+}
+
+// locate returns the file a task is kept in, given its path in the current
+// format: that path, or the task's file in the other format if only that
+// one exists.
+func locate(filename string) string {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		if _, err := os.Stat(otherFormat(filename)); err == nil {
+			return otherFormat(filename)
+		}
+	}
+	return filename
+}
+
+// move writes t to the file to, and removes the file from if it is another
+// one, such as the task's file in the trash or in the other format.
+func (s *Store) move(from, to string, t *task.Task) error {
+	if err := s.write(to, t); err != nil {
+		return err
+	}
+	if from != to {
+		if err := os.Remove(from); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *Store) Save(t *task.Task) error {
-	data, err := json.MarshalIndent(t, "", "  ")
+	old, err := s.Load(t.ID)
 	if err != nil {
+		old = nil
+	}
+	if err := s.hookTask(old, t); err != nil {
 		return err
 	}
-	filename := filepath.Join(s.dataDir, t.ID+".json")
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	from := locate(s.path(t.ID))
+	if err := s.move(from, s.path(t.ID), t); err != nil {
 		return err
 	}
 
-	return s.repo.Commit(fmt.Sprintf("Update task: %s", t.ID[:8]))
+	if err := s.repo.CommitPaths(s.saveMessage(old, t), from, s.path(t.ID)); err != nil {
+		return err
+	}
+	s.emit(EventSaved, t.ID, t)
+	return nil
 }
 
 func (s *Store) Load(id string) (*task.Task, error) {
-	filename := filepath.Join(s.dataDir, id+".json")
-	data, err := os.ReadFile(filename)
+	return s.read(locate(s.path(id)))
+}
+
+// LoadTrashed loads a task from the trash.
+func (s *Store) LoadTrashed(id string) (*task.Task, error) {
+	return s.read(locate(s.trashPath(id)))
+}
+
+// Delete moves a task into the trash and stamps it with the deletion time.
+// Use Purge to remove it for good.
+func (s *Store) Delete(id string) error {
+	t, err := s.Load(id)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var t task.Task
-	if err := json.Unmarshal(data, &t); err != nil {
-		return nil, err
+	old := *t
+	t.Trash()
+	if _, err := s.runHooks(HookDelete, &old, t); err != nil {
+		return err
 	}
-	return &t, nil
+	from := locate(s.path(id))
+	if err := s.move(from, s.trashPath(id), t); err != nil {
+		return err
+	}
+	if err := s.repo.CommitPaths(s.taskMessage("Trash", t, nil), from, s.trashPath(id)); err != nil {
+		return err
+	}
+	s.emit(EventTrashed, id, t)
+	return nil
 }
 
-func (s *Store) Delete(id string) error {
-	filename := filepath.Join(s.dataDir, id+".json")
+// Restore moves a task out of the trash back into the data dir.
+func (s *Store) Restore(id string) error {
+	t, err := s.LoadTrashed(id)
+	if err != nil {
+		return err
+	}
+	old := *t
+	t.Untrash()
+	if err := s.hookTask(&old, t); err != nil {
+		return err
+	}
+	from := locate(s.trashPath(id))
+	if err := s.move(from, s.path(id), t); err != nil {
+		return err
+	}
+	if err := s.repo.CommitPaths(s.taskMessage("Restore", t, nil), s.path(id), from); err != nil {
+		return err
+	}
+	s.emit(EventRestored, id, t)
+	return nil
+}
+
+// Purge permanently removes a task from the trash.
+func (s *Store) Purge(id string) error {
+	t, err := s.LoadTrashed(id)
+	if err != nil {
+		// Unreadable files can be purged too; the message just lacks a title.
+		t = &task.Task{ID: id}
+	}
+	if _, err := s.runHooks(HookDelete, t, nil); err != nil {
+		return err
+	}
+	filename := locate(s.trashPath(id))
 	if err := os.Remove(filename); err != nil {
 		return err
 	}
-	return s.repo.Commit(fmt.Sprintf("Delete task: %s", id[:8]))
+	if err := s.repo.CommitPaths(s.taskMessage("Delete", t, nil), filename); err != nil {
+		return err
+	}
+	s.emit(EventPurged, id, nil)
+	return nil
+}
+
+// EmptyTrash purges every trashed task deleted before the given time and
+// records the result in a single commit. It returns the number of tasks
+// purged.
+func (s *Store) EmptyTrash(before time.Time) (int, error) {
+	tasks, err := s.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+
+	var due []*task.Task
+	for _, t := range tasks {
+		if t.DeletedAt == nil || t.DeletedAt.Before(before) {
+			due = append(due, t)
+		}
+	}
+	if err := s.hookPurges(due); err != nil {
+		return 0, err
+	}
+
+	var purged, paths []string
+	for _, t := range due {
+		filename := locate(s.trashPath(t.ID))
+		if err := os.Remove(filename); err != nil {
+			return len(purged), err
+		}
+		purged = append(purged, t.ID)
+		paths = append(paths, filename)
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	if err := s.repo.CommitPaths(fmt.Sprintf("Empty trash: %d tasks", len(purged)), paths...); err != nil {
+		return 0, err
+	}
+	for _, id := range purged {
+		s.emit(EventPurged, id, nil)
+	}
+	return len(purged), nil
 }
 
 func (s *Store) List(archived bool) ([]*task.Task, error) {
-	entries, err := os.ReadDir(s.dataDir)
+	all, err := s.listDir(s.dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*task.Task
+	for _, t := range all {
+		if t.Archived == archived {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+// ListTrash returns all tasks currently in the trash.
+func (s *Store) ListTrash() ([]*task.Task, error) {
+	tasks, err := s.listDir(filepath.Join(s.dataDir, trashDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return tasks, err
+}
+
+func (s *Store) listDir(dir string) ([]*task.Task, error) {
+	files, err := s.taskFiles(dir)
 	if err != nil {
 		return nil, err
 	}
 
 	var tasks []*task.Task
+	for _, file := range files {
+		t, err := s.read(file)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// taskFiles returns the paths of the task files in the shards of dir, the
+// data dir or the trash. A file in the other format is left out when the
+// task also has one in the current format.
+func (s *Store) taskFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
 	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+		if !isShard(entry) {
 			continue
 		}
-		id := entry.Name()[:len(entry.Name())-5]
-		t, err := s.Load(id)
+		sub := filepath.Join(dir, entry.Name())
+		shardEntries, err := os.ReadDir(sub)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		if t.Archived == archived {
-			tasks = append(tasks, t)
+		names := map[string]bool{}
+		for _, e := range shardEntries {
+			if s.isTaskFile(e) {
+				names[e.Name()] = true
+			}
+		}
+		for _, e := range shardEntries {
+			name := e.Name()
+			if !names[name] || (filepath.Ext(name) != s.format.ext() && names[otherFormat(name)]) {
+				continue
+			}
+			files = append(files, filepath.Join(sub, name))
 		}
 	}
-	return tasks, nil
+	return files, nil
+}
+
+func (s *Store) read(filename string) (*task.Task, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(filename, data)
+}
+
+// decode parses the contents of a task file, decrypting it first if needed.
+// The file name picks the format and fills in a missing task ID. Errors
+// other than ErrLocked name the file.
+func (s *Store) decode(filename string, data []byte) (*task.Task, error) {
+	t, err := s.parse(filename, data)
+	if err != nil && err != ErrLocked {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return t, err
+}
+
+// parse is decode without the file name in errors.
+func (s *Store) parse(filename string, data []byte) (*task.Task, error) {
+	var err error
+	if crypt.IsSealed(data) {
+		if s.key == nil {
+			return nil, ErrLocked
+		}
+		if data, err = s.key.Open(data, sealName(filename)); err != nil {
+			// The key was checked on unlock, so the file is what is wrong.
+			if errors.Is(err, crypt.ErrWrongKey) {
+				err = errTampered
+			}
+			return nil, err
+		}
+	}
+	t, err := decodeTask(formatForFile(filename), data)
+	if err != nil {
+		return nil, err
+	}
+	if t.ID == "" {
+		t.ID = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return t, nil
+}
+
+func (s *Store) write(filename string, t *task.Task) error {
+	data, err := s.encode(filename, s.format, t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// encode returns the contents of a task file, encrypted if the store is.
+func (s *Store) encode(filename string, f Format, t *task.Task) ([]byte, error) {
+	if s.Locked() {
+		return nil, ErrLocked
+	}
+	data, err := encodeTask(f, t)
+	if err != nil {
+		return nil, err
+	}
+	if s.key != nil {
+		return s.key.Seal(data, sealName(filename))
+	}
+	return data, nil
+}
+
+// sealName is what an encrypted task file is bound to: its name without
+// the extension, which is the task ID. Moving the file to or from the trash
+// or changing the format keeps it readable, while renaming it to another
+// task's ID does not.
+func sealName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
 }
 
 func (s *Store) DataDir() string {
 	return s.dataDir
 }
 
-func (s *Store) Log() ([]string, error) {
-	return s.repo.Log()
+// Flush brings the git index up to date with the commits the store made,
+// which skip it to stay fast, so plain git sees a clean worktree. Call it
+// before exiting; the store stays usable.
+func (s *Store) Flush() error {
+	return s.repo.SyncIndex()
+}
+
+// Head returns the hash of the latest commit, or "" if there is none.
+func (s *Store) Head() (string, error) {
+	return s.repo.Head()
+}
+
+// WriteBundle writes the repo history as a git bundle.
+func (s *Store) WriteBundle(w io.Writer) error {
+	return s.repo.WriteBundle(w)
 }
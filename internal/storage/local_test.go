@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/invar/internal/task"
+)
+
+// BenchmarkSave measures saving one task in data dirs of growing size. The
+// time per save should stay about the same.
+func BenchmarkSave(b *testing.B) {
+	for _, n := range []int{100, 2000, 10000, 50000} {
+		// Seed outside b.Run, which calls its function once per b.N tried.
+		s, err := New(b.TempDir())
+		if err != nil {
+			b.Fatal(err)
+		}
+		tasks := make([]*task.Task, n)
+		for i := range tasks {
+			tasks[i] = task.New(fmt.Sprintf("task %d", i))
+		}
+		if err := s.SaveAll(tasks, "Seed"); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("tasks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				t := tasks[i%n]
+				t.CyclePriority()
+				if err := s.Save(t); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestOtherFilesAreNotTasks checks that files a user keeps at the root of
+// the data dir stay where they are and are not read as tasks, while a task
+// file left there by an older version still moves into its shard.
+func TestOtherFilesAreNotTasks(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := task.New("from before shards")
+	if err := s.Save(old); err != nil {
+		t.Fatal(err)
+	}
+	flat := filepath.Join(dir, old.ID+".json")
+	if err := os.Rename(s.path(old.ID), flat); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"README.md", "notes.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("mine\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.repo.Commit("Add notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"README.md", "notes.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s moved: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(s.path(old.ID)); err != nil {
+		t.Errorf("task file was not moved into its shard: %v", err)
+	}
+	entries, err := s.Log(LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		for _, c := range e.Tasks {
+			if c.ID != old.ID {
+				t.Errorf("%q lists task %q", e.Subject(), c.ID)
+			}
+		}
+	}
+	problems, err := s.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Check = %v, want no problems", problems)
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"path"
+	"strings"
+
+	"github.com/user/invar/internal/git"
+)
+
+// LogOptions selects the commits Log returns. A Task limits them to the
+// commits that touched that task, in the data dir or the trash.
+type LogOptions struct {
+	git.LogOptions
+	Task string
+}
+
+// TaskChange is a task a commit added, modified or deleted. Moving a task
+// to or from the trash counts as modifying it.
+type TaskChange struct {
+	ID   string         `json:"id"`
+	Type git.ChangeType `json:"type"`
+}
+
+// LogEntry is a commit with the tasks it touched.
+type LogEntry struct {
+	git.Commit
+	Tasks []TaskChange `json:"tasks,omitempty"`
+}
+
+// Log returns the commits in the repo, newest first.
+func (s *Store) Log(opts LogOptions) ([]LogEntry, error) {
+	if opts.Task != "" {
+		opts.Paths = append(opts.Paths, taskPaths(opts.Task)...)
+	}
+	commits, err := s.repo.Log(opts.LogOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LogEntry, len(commits))
+	for i, c := range commits {
+		entries[i].Commit = c
+		index := map[string]int{}
+		for _, f := range c.Files {
+			id, ok := taskID(f.Path)
+			if !ok {
+				continue
+			}
+			j, seen := index[id]
+			if !seen {
+				index[id] = len(entries[i].Tasks)
+				entries[i].Tasks = append(entries[i].Tasks, TaskChange{ID: id, Type: f.Type})
+				continue
+			}
+			if entries[i].Tasks[j].Type != f.Type {
+				entries[i].Tasks[j].Type = git.ChangeModify
+			}
+		}
+	}
+	return entries, nil
+}
+
+// taskPaths returns every path a task's file can have in the repo,
+// including the ones from before files were sharded.
+func taskPaths(id string) []string {
+	var paths []string
+	for _, dir := range []string{"", trashDir + "/"} {
+		for _, ext := range []string{".json", ".md"} {
+			paths = append(paths, dir+shard(id)+"/"+id+ext, dir+id+ext)
+		}
+	}
+	return paths
+}
+
+// taskID returns the ID of the task stored at p, a path in the repo, and
+// false for files that are not tasks. Files directly in the data dir or the
+// trash count, as that is where they were kept before they were sharded.
+func taskID(p string) (string, bool) {
+	dir, name := path.Split(p)
+	ext := path.Ext(name)
+	if ext != ".json" && ext != ".md" {
+		return "", false
+	}
+	id := strings.TrimSuffix(name, ext)
+	if !validID(id) {
+		return "", false
+	}
+	dir = strings.TrimPrefix(dir, trashDir+"/")
+	if dir != "" && dir != shard(id)+"/" {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/task"
+)
+
+// TestLogPages checks that Skip and Limit page through the commits left
+// after filtering by task, and that Since cuts the log off.
+func TestLogPages(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := task.New("a"), task.New("b")
+	for i := 0; i < 3; i++ {
+		a.CyclePriority()
+		b.CyclePriority()
+		if err := s.Save(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := s.Log(LogOptions{Task: a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Log for a = %d commits, want 3", len(all))
+	}
+	page, err := s.Log(LogOptions{Task: a.ID, LogOptions: git.LogOptions{Skip: 1, Limit: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].Hash != all[1].Hash {
+		t.Fatalf("page = %v, want the second commit for a", page)
+	}
+	if len(page[0].Tasks) != 1 || page[0].Tasks[0].ID != a.ID {
+		t.Errorf("page tasks = %v, want only a", page[0].Tasks)
+	}
+
+	later, err := s.Log(LogOptions{LogOptions: git.LogOptions{Since: time.Now().Add(time.Hour)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(later) != 0 {
+		t.Errorf("Log since an hour from now = %d commits, want none", len(later))
+	}
+	earlier, err := s.Log(LogOptions{LogOptions: git.LogOptions{Since: time.Now().Add(-time.Hour)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(earlier) != 6 {
+		t.Errorf("Log since an hour ago = %d commits, want 6", len(earlier))
+	}
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/user/invar/internal/crypt"
+	"github.com/user/invar/internal/merge"
+	"github.com/user/invar/internal/task"
+)
+
+// conflictsFile lists the merge conflicts waiting for the user. Like the
+// sync status it lives inside .git, as it is a local matter. It holds task
+// content, so an encrypted store seals it like a task file.
+const conflictsFile = "invar-conflicts.json"
+
+// Conflict is a merge conflict in a task, found while syncing.
+type Conflict struct {
+	merge.Conflict
+	TaskID string    `json:"task_id"`
+	Path   string    `json:"path"`
+	At     time.Time `json:"at"`
+	// The two versions the conflict came from, for resolving it later.
+	OursTask   *task.Task `json:"ours_task,omitempty"`
+	TheirsTask *task.Task `json:"theirs_task,omitempty"`
+}
+
+// MergeFile merges two versions of a task file, path relative to the data
+// dir, changed independently from base, which is nil if both sides added
+// the file. It returns the merged file and the conflicts found on the way.
+// If one side cannot be read, the other is kept.
+func (s *Store) MergeFile(p string, base, ours, theirs []byte) ([]byte, []Conflict, error) {
+	our, oerr := s.decode(p, ours)
+	their, terr := s.decode(p, theirs)
+	switch {
+	case oerr != nil && terr != nil:
+		return nil, nil, oerr
+	case oerr != nil:
+		return theirs, nil, nil
+	case terr != nil:
+		return ours, nil, nil
+	}
+	var baseTask *task.Task
+	if base != nil {
+		// A base we cannot read is as good as none.
+		baseTask, _ = s.decode(p, base)
+	}
+
+	merged, found := merge.Tasks(baseTask, our, their)
+	data, err := s.encode(p, formatForFile(p), merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conflicts []Conflict
+	for _, c := range found {
+		conflicts = append(conflicts, Conflict{
+			Conflict: c, TaskID: merged.ID, Path: p, At: time.Now(),
+			OursTask: our, TheirsTask: their,
+		})
+	}
+	return data, conflicts, nil
+}
+
+// mergeFile settles a file changed on both sides of a sync. Task files are
+// merged field by field, and an edit wins over a deletion. Other files keep
+// the local version.
+func (s *Store) mergeFile(p string, base, ours, theirs []byte) ([]byte, []Conflict, error) {
+	_, isTask := taskID(p)
+	switch {
+	case ours == nil:
+		return theirs, nil, nil
+	case theirs == nil || !isTask:
+		return ours, nil, nil
+	}
+	return s.MergeFile(p, base, ours, theirs)
+}
+
+func (s *Store) conflictsPath() string {
+	return filepath.Join(s.dataDir, ".git", conflictsFile)
+}
+
+// Conflicts returns the merge conflicts syncing could not settle.
+func (s *Store) Conflicts() ([]Conflict, error) {
+	data, err := os.ReadFile(s.conflictsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if crypt.IsSealed(data) {
+		if s.key == nil {
+			return nil, ErrLocked
+		}
+		if data, err = s.key.Open(data, conflictsFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.conflictsPath(), err)
+		}
+	}
+	var conflicts []Conflict
+	if err := json.Unmarshal(data, &conflicts); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.conflictsPath(), err)
+	}
+	return conflicts, nil
+}
+
+func (s *Store) saveConflicts(conflicts []Conflict) error {
+	if len(conflicts) == 0 {
+		err := os.Remove(s.conflictsPath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(conflicts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.Encrypted() {
+		if s.key == nil {
+			return ErrLocked
+		}
+		if data, err = s.key.Seal(data, conflictsFile); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(s.conflictsPath(), data, 0644)
+}
+
+// addConflicts records unresolved conflicts, replacing older ones for the
+// same task field.
+func (s *Store) addConflicts(found []Conflict) error {
+	if len(found) == 0 {
+		return nil
+	}
+	conflicts, err := s.Conflicts()
+	if err != nil {
+		return err
+	}
+	for _, c := range found {
+		conflicts = removeConflict(conflicts, c.TaskID, c.Field)
+		conflicts = append(conflicts, c)
+	}
+	return s.saveConflicts(conflicts)
+}
+
+func removeConflict(conflicts []Conflict, id, field string) []Conflict {
+	var kept []Conflict
+	for _, c := range conflicts {
+		if c.TaskID != id || c.Field != field {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// ResolveConflict settles a recorded conflict by giving the task the value
+// of the field from one side, "ours" or "theirs", as a new commit.
+func (s *Store) ResolveConflict(c Conflict, side string) error {
+	var src *task.Task
+	switch side {
+	case "ours":
+		src = c.OursTask
+	case "theirs":
+		src = c.TheirsTask
+	default:
+		return fmt.Errorf("side must be ours or theirs, not %q", side)
+	}
+
+	target := s.path(c.TaskID)
+	filename := locate(target)
+	t, err := s.read(filename)
+	if os.IsNotExist(err) {
+		target = s.trashPath(c.TaskID)
+		filename = locate(target)
+		t, err = s.read(filename)
+	}
+	if err != nil {
+		return err
+	}
+	if src != nil {
+		if err := merge.Apply(t, src, c.Field); err != nil {
+			return err
+		}
+		if err := s.move(filename, target, t); err != nil {
+			return err
+		}
+		action := fmt.Sprintf("Resolve %s conflict with %s", c.Field, side)
+		if err := s.repo.CommitPaths(s.taskMessage(action, t, []task.Change{{Field: c.Field}}), filename, target); err != nil {
+			return err
+		}
+		s.emit(EventSaved, t.ID, t)
+	}
+
+	conflicts, err := s.Conflicts()
+	if err != nil {
+		return err
+	}
+	return s.saveConflicts(removeConflict(conflicts, c.TaskID, c.Field))
+}
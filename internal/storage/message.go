@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/invar/internal/task"
+)
+
+// Trailers added to commits that touch a single task, so history tools can
+// tell which task changed and how without diffing the files.
+const (
+	taskTrailer        = "Invar-Task:"
+	changedTrailer     = "Invar-Changed:"
+	restoredTrailer    = "Invar-Restored-From:"
+	resurrectedTrailer = "Invar-Resurrected-From:"
+)
+
+// maxTitle is how much of a task's content goes into a commit subject.
+const maxTitle = 50
+
+// saveMessage describes saving t over old, which is nil for a new task.
+func (s *Store) saveMessage(old, t *task.Task) string {
+	if old == nil {
+		return s.taskMessage("Add", t, nil)
+	}
+	changes := task.Diff(old, t)
+	return s.taskMessage(summary(changes, s.Encrypted()), t, changes)
+}
+
+// taskMessage builds a commit message for a change to one task: the action
+// and the task's title as the subject, then the trailers. An encrypted
+// store names the task by its short ID instead, so its content stays out
+// of the history.
+func (s *Store) taskMessage(action string, t *task.Task, changes []task.Change) string {
+	name := title(t)
+	if s.Encrypted() {
+		name = task.ShortID(t.ID)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n\n%s %s", action, name, taskTrailer, t.ID)
+	if len(changes) > 0 {
+		fields := make([]string, len(changes))
+		for i, c := range changes {
+			fields[i] = c.Field
+		}
+		fmt.Fprintf(&b, "\n%s %s", changedTrailer, strings.Join(fields, ", "))
+	}
+	return b.String()
+}
+
+// summary names the changes for a commit subject: the change itself when
+// there is one, or the changed fields. With private set it never gives the
+// values, only what kind of change it was.
+func summary(changes []task.Change, private bool) string {
+	if len(changes) != 1 {
+		if len(changes) == 0 {
+			return "Update"
+		}
+		fields := make([]string, len(changes))
+		for i, c := range changes {
+			fields[i] = c.Field
+		}
+		return "Update " + strings.Join(fields, ", ")
+	}
+
+	c := changes[0]
+	if private && (c.Field == "priority" || c.Field == "deadline") {
+		return "Update " + c.Field
+	}
+	switch c.Field {
+	case "content":
+		return "Edit"
+	case "priority":
+		return fmt.Sprintf("Priority %s→%s", c.From, c.To)
+	case "deadline":
+		if c.To == "" {
+			return "Deadline cleared"
+		}
+		return "Deadline set to " + c.To
+	case "completed":
+		if c.To == "" {
+			return "Reopen"
+		}
+		return "Complete"
+	case "archived":
+		if c.To == "" {
+			return "Unarchive"
+		}
+		return "Archive"
+	case "comments":
+		return "Comment"
+	case "deleted":
+		if c.To == "" {
+			return "Restore"
+		}
+		return "Trash"
+	}
+	return "Update " + c.Field
+}
+
+// title returns the first line of a task's content, shortened for a commit
+// subject.
+func title(t *task.Task) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(t.Content), "\n")
+	if r := []rune(line); len(r) > maxTitle {
+		line = strings.TrimSpace(string(r[:maxTitle-1])) + "…"
+	}
+	if line == "" {
+		return task.ShortID(t.ID)
+	}
+	return line
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/task"
+)
+
+// planPrefix keeps planning branches apart from the main branch and any
+// other branch made with plain git.
+const planPrefix = "plan/"
+
+// ErrPlanning is returned by Sync while a planning branch is checked out.
+// Plans stay local until they are merged.
+var ErrPlanning = errors.New("a planning branch is checked out; switch back to the main branch to sync")
+
+// TaskDiff is a task a planning branch added, changed or deleted. Old or
+// Task is nil when the task is missing on that side.
+type TaskDiff struct {
+	ID      string
+	Type    git.ChangeType
+	Old     *task.Task
+	Task    *task.Task
+	Changes []task.Change
+}
+
+// String describes the change on one line: "+" and the title for an added
+// task, "-" for a deleted one, and "~" with what changed otherwise.
+func (d TaskDiff) String() string {
+	switch d.Type {
+	case git.ChangeAdd:
+		return "+ " + title(d.Task)
+	case git.ChangeDelete:
+		return "- " + title(d.Old)
+	}
+	return "~ " + title(d.Task) + ": " + summary(d.Changes, false)
+}
+
+// Plan returns the name of the planning branch checked out, or "" when the
+// main branch is.
+func (s *Store) Plan() (string, error) {
+	branch, err := s.repo.Branch()
+	if err != nil {
+		return "", err
+	}
+	name, ok := strings.CutPrefix(branch, planPrefix)
+	if !ok {
+		return "", nil
+	}
+	return name, nil
+}
+
+// Plans returns the names of the planning branches, sorted.
+func (s *Store) Plans() ([]string, error) {
+	branches, err := s.repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	var plans []string
+	for _, b := range branches {
+		if name, ok := strings.CutPrefix(b, planPrefix); ok {
+			plans = append(plans, name)
+		}
+	}
+	return plans, nil
+}
+
+// MainBranch returns the name of the branch plans are merged into.
+func (s *Store) MainBranch() (string, error) {
+	branch, err := s.repo.Branch()
+	if err != nil || !strings.HasPrefix(branch, planPrefix) {
+		return branch, err
+	}
+	return s.upstream(strings.TrimPrefix(branch, planPrefix))
+}
+
+// upstream returns the branch a plan was started from.
+func (s *Store) upstream(plan string) (string, error) {
+	main, err := s.repo.Upstream(planPrefix + plan)
+	if err != nil {
+		return "", err
+	}
+	if main == "" {
+		return "", fmt.Errorf("plan %q does not track a branch", plan)
+	}
+	return main, nil
+}
+
+// CreatePlan starts a planning branch from the tasks as they are now and
+// switches to it. Plans started from another plan still merge into the
+// main branch.
+func (s *Store) CreatePlan(name string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid plan name %q", name)
+	}
+	main, err := s.MainBranch()
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Commit("Commit local changes"); err != nil {
+		return err
+	}
+	if err := s.repo.CreateBranch(planPrefix+name, main); err != nil {
+		if errors.Is(err, git.ErrNoCommits) {
+			return errors.New("add a task before starting a plan")
+		}
+		if errors.Is(err, git.ErrBranchName) {
+			return fmt.Errorf("invalid plan name %q", name)
+		}
+		return err
+	}
+	return s.checkout(planPrefix + name)
+}
+
+// SwitchPlan checks out the named planning branch, or the main branch for
+// an empty name.
+func (s *Store) SwitchPlan(name string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	branch := planPrefix + name
+	if name == "" {
+		var err error
+		if branch, err = s.MainBranch(); err != nil {
+			return err
+		}
+	} else if err := s.hasPlan(name); err != nil {
+		return err
+	}
+	if err := s.repo.Commit("Commit local changes"); err != nil {
+		return err
+	}
+	return s.checkout(branch)
+}
+
+// checkout switches branches and picks up the settings of the new one.
+func (s *Store) checkout(branch string) error {
+	if err := s.repo.Checkout(branch); err != nil {
+		return err
+	}
+	if err := s.loadSettings(); err != nil {
+		return err
+	}
+	s.emit(EventSwitched, "", nil)
+	return nil
+}
+
+func (s *Store) hasPlan(name string) error {
+	plans, err := s.Plans()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(plans, name) {
+		return fmt.Errorf("no plan %q", name)
+	}
+	return nil
+}
+
+// PlanDiff returns the tasks a plan changed since it was started, the way
+// merging it would see them.
+func (s *Store) PlanDiff(name string) ([]TaskDiff, error) {
+	if err := s.hasPlan(name); err != nil {
+		return nil, err
+	}
+	if current, err := s.Plan(); err != nil {
+		return nil, err
+	} else if current == name {
+		if err := s.repo.Commit("Commit local changes"); err != nil {
+			return nil, err
+		}
+	}
+	main, err := s.upstream(name)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.repo.BranchDiff(planPrefix+name, main)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []TaskDiff
+	index := map[string]int{}
+	for _, f := range files {
+		id, ok := taskID(f.Path)
+		if !ok {
+			continue
+		}
+		i, seen := index[id]
+		if !seen {
+			i = len(diffs)
+			index[id] = i
+			diffs = append(diffs, TaskDiff{ID: id})
+		}
+		// A move to or from the trash shows up as a delete and an add.
+		if f.Old != nil {
+			if diffs[i].Old, err = s.decode(f.Path, f.Old); err != nil {
+				return nil, err
+			}
+		}
+		if f.New != nil {
+			if diffs[i].Task, err = s.decode(f.Path, f.New); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Tasks saved without a change that shows, such as a new update time
+	// alone, are left out.
+	changed := diffs[:0]
+	for _, d := range diffs {
+		switch {
+		case d.Old == nil:
+			d.Type = git.ChangeAdd
+		case d.Task == nil:
+			d.Type = git.ChangeDelete
+		default:
+			d.Type = git.ChangeModify
+			if d.Changes = task.Diff(d.Old, d.Task); len(d.Changes) == 0 {
+				continue
+			}
+		}
+		changed = append(changed, d)
+	}
+	return changed, nil
+}
+
+// MergePlan merges a plan into the main branch, checks the main branch out
+// and removes the plan. Tasks changed on both go through the same field by
+// field merge as a sync; conflicts it cannot settle are kept for
+// Conflicts and returned. The main branch stays checked out if the merge
+// fails.
+func (s *Store) MergePlan(name string) (unresolved []Conflict, err error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if err := s.hasPlan(name); err != nil {
+		return nil, err
+	}
+	main, err := s.upstream(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Commit("Commit local changes"); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Checkout(main); err != nil {
+		return nil, err
+	}
+	// The main branch stays checked out even if the merge fails.
+	defer func() {
+		if serr := s.loadSettings(); err == nil {
+			err = serr
+		}
+		s.emit(EventSwitched, "", nil)
+	}()
+
+	var conflicts []Conflict
+	resolve := func(p string, base, ours, theirs []byte) ([]byte, error) {
+		data, found, err := s.mergeFile(p, base, ours, theirs)
+		conflicts = append(conflicts, found...)
+		return data, err
+	}
+	if _, err := s.repo.MergeBranch(planPrefix+name, "Merge plan "+name, resolve); err != nil {
+		return conflicts, err
+	}
+	for _, c := range conflicts {
+		if !c.Resolved {
+			unresolved = append(unresolved, c)
+		}
+	}
+	if err := s.addConflicts(unresolved); err != nil {
+		return unresolved, err
+	}
+	return unresolved, s.repo.DeleteBranch(planPrefix + name)
+}
+
+// DiscardPlan throws a plan away, switching to the main branch first if
+// the plan is checked out.
+func (s *Store) DiscardPlan(name string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if err := s.hasPlan(name); err != nil {
+		return err
+	}
+	current, err := s.Plan()
+	if err != nil {
+		return err
+	}
+	if current == name {
+		main, err := s.upstream(name)
+		if err != nil {
+			return err
+		}
+		// Changes not committed yet belong to the plan, so they go too.
+		if err := s.repo.Commit("Commit local changes"); err != nil {
+			return err
+		}
+		if err := s.checkout(main); err != nil {
+			return err
+		}
+	}
+	return s.repo.DeleteBranch(planPrefix + name)
+}
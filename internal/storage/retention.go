@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// Retention says how long tasks stay where they are. A zero duration
+// disables that rule.
+type Retention struct {
+	// ArchiveCompleted archives tasks completed longer ago than this.
+	ArchiveCompleted time.Duration
+	// PurgeArchived deletes archived tasks archived longer ago than this.
+	PurgeArchived time.Duration
+	// EmptyTrash purges trashed tasks deleted longer ago than this.
+	EmptyTrash time.Duration
+}
+
+// RetentionReport lists the tasks a retention run changed.
+type RetentionReport struct {
+	Archived []*task.Task
+	Purged   []*task.Task
+	Emptied  []*task.Task
+}
+
+// Empty reports whether the run changed nothing.
+func (r RetentionReport) Empty() bool {
+	return len(r.Archived)+len(r.Purged)+len(r.Emptied) == 0
+}
+
+func (r RetentionReport) String() string {
+	var parts []string
+	if n := len(r.Archived); n > 0 {
+		parts = append(parts, fmt.Sprintf("archived %d", n))
+	}
+	if n := len(r.Purged); n > 0 {
+		parts = append(parts, fmt.Sprintf("purged %d archived", n))
+	}
+	if n := len(r.Emptied); n > 0 {
+		parts = append(parts, fmt.Sprintf("purged %d trashed", n))
+	}
+	if len(parts) == 0 {
+		return "nothing to do"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ApplyRetention applies the retention rules as of now and records every
+// change in a single commit. With dryRun it only reports what would change.
+func (s *Store) ApplyRetention(r Retention, now time.Time, dryRun bool) (RetentionReport, error) {
+	var report RetentionReport
+	if r == (Retention{}) {
+		return report, nil
+	}
+
+	all, err := s.listDir(s.dataDir)
+	if err != nil {
+		return report, err
+	}
+	for _, t := range all {
+		switch {
+		case !t.Archived && r.ArchiveCompleted > 0 && t.CompletedAt != nil &&
+			now.Sub(*t.CompletedAt) > r.ArchiveCompleted:
+			report.Archived = append(report.Archived, t)
+		case t.Archived && r.PurgeArchived > 0 && now.Sub(archivedAt(t)) > r.PurgeArchived:
+			report.Purged = append(report.Purged, t)
+		}
+	}
+	if r.EmptyTrash > 0 {
+		trashed, err := s.ListTrash()
+		if err != nil {
+			return report, err
+		}
+		for _, t := range trashed {
+			if t.DeletedAt == nil || now.Sub(*t.DeletedAt) > r.EmptyTrash {
+				report.Emptied = append(report.Emptied, t)
+			}
+		}
+	}
+	if dryRun || report.Empty() {
+		return report, nil
+	}
+
+	// Every hook runs before anything changes, so a hook refusing one
+	// change stops the run.
+	archived := make([]*task.Task, len(report.Archived))
+	for i, t := range report.Archived {
+		c := *t
+		c.Archive()
+		if err := s.hookTask(t, &c); err != nil {
+			return report, err
+		}
+		archived[i] = &c
+	}
+	if err := s.hookPurges(append(slices.Clone(report.Purged), report.Emptied...)); err != nil {
+		return report, err
+	}
+
+	var paths []string
+	for i, t := range report.Archived {
+		*t = *archived[i]
+		from := locate(s.path(t.ID))
+		if err := s.move(from, s.path(t.ID), t); err != nil {
+			return report, err
+		}
+		paths = append(paths, from, s.path(t.ID))
+	}
+	for _, t := range report.Purged {
+		filename := locate(s.path(t.ID))
+		if err := os.Remove(filename); err != nil {
+			return report, err
+		}
+		paths = append(paths, filename)
+	}
+	for _, t := range report.Emptied {
+		filename := locate(s.trashPath(t.ID))
+		if err := os.Remove(filename); err != nil {
+			return report, err
+		}
+		paths = append(paths, filename)
+	}
+
+	msg := "Retention: " + report.String()
+	if err := s.repo.CommitPaths(msg, paths...); err != nil {
+		return report, err
+	}
+	for _, t := range report.Archived {
+		s.emit(EventSaved, t.ID, t)
+	}
+	for _, t := range append(report.Purged, report.Emptied...) {
+		s.emit(EventPurged, t.ID, nil)
+	}
+	return report, nil
+}
+
+// archivedAt returns when a task was archived. Tasks archived before the
+// time was recorded fall back to their last update.
+func archivedAt(t *task.Task) time.Time {
+	if t.ArchivedAt != nil {
+		return *t.ArchivedAt
+	}
+	return t.UpdatedAt
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/user/invar/internal/task"
+)
+
+// TestRetentionCommitsItsOwnPaths checks that a retention run commits the
+// tasks it changed and leaves other files in the data dir alone.
+func TestRetentionCommitsItsOwnPaths(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := task.New("done long ago")
+	done.Complete()
+	if err := s.Save(done); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.DataDir(), "notes.txt"), []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.ApplyRetention(Retention{ArchiveCompleted: time.Hour}, time.Now().Add(2*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Archived) != 1 {
+		t.Fatalf("report = %s, want one task archived", report)
+	}
+	dirty, err := s.repo.Dirty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(dirty, []string{"notes.txt"}) {
+		t.Errorf("Dirty = %v, want only notes.txt", dirty)
+	}
+	if got, err := s.Load(done.ID); err != nil || !got.Archived {
+		t.Errorf("Load = %+v, %v, want it archived", got, err)
+	}
+}
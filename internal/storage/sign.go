@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"os"
+
+	"github.com/user/invar/internal/git"
+)
+
+// Signing says how new commits are signed and whose signatures count as
+// trusted. Format is "openpgp" or "ssh", or empty for unsigned commits.
+type Signing struct {
+	Format     string
+	Key        string
+	Passphrase string
+	Trusted    []string
+}
+
+// SetSigning loads the signing key and the trusted keys. Commits made from
+// then on are signed, and the keys are used by VerifySignatures and to
+// check the commits a sync brings in.
+func (s *Store) SetSigning(sig Signing) error {
+	var files [][]byte
+	for _, p := range sig.Trusted {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, data)
+	}
+	keyring, err := git.NewKeyring(files...)
+	if err != nil {
+		return err
+	}
+
+	var signer git.Signer
+	if sig.Format != "" {
+		key, err := os.ReadFile(sig.Key)
+		if err != nil {
+			return err
+		}
+		signer, err = git.NewSigner(sig.Format, key, []byte(sig.Passphrase))
+		if err != nil {
+			return err
+		}
+		keyring.Trust(signer)
+	}
+
+	s.signing = sig
+	s.repo.SetSigner(signer)
+	s.keyring = nil
+	if sig.Format != "" || len(sig.Trusted) > 0 {
+		s.keyring = keyring
+	}
+	return nil
+}
+
+// Signing returns the settings last passed to SetSigning.
+func (s *Store) Signing() Signing {
+	return s.signing
+}
+
+// VerifySignatures checks the signature of every commit, newest first.
+func (s *Store) VerifySignatures() ([]git.SignatureCheck, error) {
+	keyring := s.keyring
+	if keyring == nil {
+		keyring = &git.Keyring{}
+	}
+	return s.repo.CheckSignatures(keyring, "")
+}
+
+// unverified returns the commits added since the given one whose signature
+// is missing, untrusted or bad. It finds nothing unless signing is set up.
+func (s *Store) unverified(since string) ([]git.SignatureCheck, error) {
+	if s.keyring == nil {
+		return nil, nil
+	}
+	checks, err := s.repo.CheckSignatures(s.keyring, since)
+	if err != nil {
+		return nil, err
+	}
+	var bad []git.SignatureCheck
+	for _, c := range checks {
+		if c.Status != git.SignatureGood {
+			bad = append(bad, c)
+		}
+	}
+	return bad, nil
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/invar/internal/crypt"
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/task"
+)
+
+// syncStatusFile records the outcome of the last sync. It lives inside
+// .git so it is never committed or synced itself.
+const syncStatusFile = "invar-sync.json"
+
+// SyncStatus is the outcome of the last sync attempt.
+type SyncStatus struct {
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	Error       string    `json:"error,omitempty"`
+	// Unverified counts the commits the last sync that pulled anything
+	// brought in without a trusted signature.
+	Unverified int `json:"unverified,omitempty"`
+}
+
+// Remote returns the URL tasks are synced with, or "" if there is none.
+func (s *Store) Remote() (string, error) {
+	return s.repo.Remote()
+}
+
+// SetRemote sets the URL tasks are synced with. A local path that does not
+// exist yet is created as a bare repo.
+func (s *Store) SetRemote(url string) error {
+	return s.repo.SetRemote(url)
+}
+
+// SyncStatus returns the outcome of the last sync attempt. It is the zero
+// value if the data dir has never been synced.
+func (s *Store) SyncStatus() SyncStatus {
+	var st SyncStatus
+	data, err := os.ReadFile(filepath.Join(s.dataDir, ".git", syncStatusFile))
+	if err == nil {
+		json.Unmarshal(data, &st)
+	}
+	return st
+}
+
+func (s *Store) saveSyncStatus(st SyncStatus) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dataDir, ".git", syncStatusFile), data, 0644)
+}
+
+// SyncResult says what a sync did, including the task conflicts it met.
+// Conflicts it could not settle are also kept for Conflicts.
+type SyncResult struct {
+	git.SyncResult
+	Conflicts  []Conflict
+	Unverified []git.SignatureCheck
+}
+
+// Sync commits any uncommitted changes, then pulls from and pushes to the
+// remote. Tasks edited on both sides are merged field by field. The outcome
+// is recorded for SyncStatus. After a pull, the caller runs Reload on the
+// goroutine that uses the store, as the remote may have changed settings
+// the store holds.
+func (s *Store) Sync(ctx context.Context) (SyncResult, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	st := s.SyncStatus()
+	st.LastAttempt = time.Now()
+
+	result, err := s.sync(ctx)
+	if err != nil {
+		st.Error = err.Error()
+	} else {
+		st.Error = ""
+		st.LastSuccess = st.LastAttempt
+		if result.Pulled {
+			st.Unverified = len(result.Unverified)
+		}
+	}
+	if serr := s.saveSyncStatus(st); err == nil {
+		err = serr
+	}
+	return result, err
+}
+
+func (s *Store) sync(ctx context.Context) (SyncResult, error) {
+	var result SyncResult
+	if plan, err := s.Plan(); err != nil {
+		return result, err
+	} else if plan != "" {
+		return result, ErrPlanning
+	}
+	if err := s.repo.Commit("Commit local changes"); err != nil {
+		return result, err
+	}
+	before, err := s.repo.Head()
+	if err != nil {
+		return result, err
+	}
+	resolve := func(p string, base, ours, theirs []byte) ([]byte, error) {
+		data, conflicts, err := s.mergeFile(p, base, ours, theirs)
+		result.Conflicts = append(result.Conflicts, conflicts...)
+		return data, err
+	}
+	result.SyncResult, err = s.repo.Sync(ctx, resolve)
+	if err != nil || !result.Pulled {
+		return result, err
+	}
+	if result.Unverified, err = s.unverified(before); err != nil {
+		return result, err
+	}
+
+	var unresolved []Conflict
+	for _, c := range result.Conflicts {
+		if !c.Resolved {
+			unresolved = append(unresolved, c)
+		}
+	}
+	if err := s.addConflicts(unresolved); err != nil {
+		return result, err
+	}
+
+	// A device still on an older version can bring back unsharded files.
+	changed, err := s.moveToShards()
+	if err != nil {
+		return result, err
+	}
+	if result.Merged {
+		deduped, err := s.dedupeTrash()
+		if err != nil {
+			return result, err
+		}
+		changed = changed || deduped
+	}
+	if changed {
+		if _, err := s.repo.Sync(ctx, resolve); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Reload rereads the settings and encryption parameters, which a sync can
+// change, and tells subscribers the tasks may have changed. It forgets the
+// key if it no longer opens the data dir.
+func (s *Store) Reload() error {
+	// Another store may have written packs this one has not seen.
+	if err := s.repo.Reopen(); err != nil {
+		return err
+	}
+	if err := s.loadSettings(); err != nil {
+		return err
+	}
+	params, err := crypt.LoadParams(s.cryptPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.crypt = params
+	if params == nil || (s.key != nil && params.Verify(s.key) != nil) {
+		s.key = nil
+	}
+	s.emit(EventSynced, "", nil)
+	return nil
+}
+
+// dedupeTrash removes the older copy of tasks that a merge left both in
+// the data dir and in the trash, which happens when one side trashed a task
+// the other edited.
+func (s *Store) dedupeTrash() (bool, error) {
+	trashed, err := s.ListTrash()
+	if err != nil {
+		return false, err
+	}
+	var removed []string
+	for _, t := range trashed {
+		active, err := s.Load(t.ID)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		older := locate(s.trashPath(t.ID))
+		if t.UpdatedAt.After(active.UpdatedAt) {
+			older = locate(s.path(t.ID))
+		}
+		if err := os.Remove(older); err != nil {
+			return false, err
+		}
+		removed = append(removed, task.ShortID(t.ID))
+	}
+	if len(removed) == 0 {
+		return false, nil
+	}
+	return true, s.repo.Commit(fmt.Sprintf("Resolve sync of trashed tasks: %s", strings.Join(removed, ", ")))
+}
+
+// InstallMergeDriver makes plain git merge task files with command, which
+// is run as "<command> %O %A %B %P".
+func (s *Store) InstallMergeDriver(command string) error {
+	return s.repo.InstallMergeDriver("invar", command+" %O %A %B %P",
+		[]string{"/??/*.json", "/??/*.md", "/" + trashDir + "/??/*.json", "/" + trashDir + "/??/*.md"})
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/invar/internal/task"
+)
+
+// TestSyncInBackground syncs the way the TUI does, on a reopened store on
+// another goroutine while the store is being read, and then reloads. Run
+// with -race.
+func TestSyncInBackground(t *testing.T) {
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	a, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetRemote(remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetFormat(FormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Save(task.New("from a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetRemote(remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(task.New("from b")); err != nil {
+		t.Fatal(err)
+	}
+
+	type done struct {
+		result SyncResult
+		err    error
+	}
+	bg, err := b.Reopen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan done)
+	go func() {
+		result, err := bg.Sync(context.Background())
+		ch <- done{result, err}
+	}()
+	var d done
+	for waiting := true; waiting; {
+		select {
+		case d = <-ch:
+			waiting = false
+		default:
+			if _, err := b.List(false); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := b.UndoStack(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if !d.result.Pulled {
+		t.Fatal("sync did not pull")
+	}
+
+	if b.Format() != FormatJSON {
+		t.Fatalf("format changed to %s before Reload", b.Format())
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Format() != FormatMarkdown {
+		t.Errorf("format after Reload = %s, want %s", b.Format(), FormatMarkdown)
+	}
+	tasks, err := b.List(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("listed %d tasks after the sync, want 2", len(tasks))
+	}
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/user/invar/internal/git"
+	"github.com/user/invar/internal/task"
+)
+
+// Trailers linking undo and redo commits to the commits they revert.
+const (
+	undoTrailer = "Invar-Undo-Of:"
+	redoTrailer = "Invar-Redo-Of:"
+)
+
+// maxUndo is how far back the undo stack reaches.
+const maxUndo = 50
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// UndoEntry is a commit that Undo or Redo would revert.
+type UndoEntry struct {
+	Commit  string
+	Subject string
+}
+
+// UndoStack returns what Undo and Redo would revert, next first. Both
+// stacks are derived from the git history, so they survive restarts.
+// Only changes to single tasks can be undone; anything else, such as an
+// import or a sync, ends the undo stack.
+func (s *Store) UndoStack() (undo, redo []UndoEntry, err error) {
+	undone := map[string]bool{}
+	redone := map[string]bool{}
+	redoOpen := true
+	err = s.repo.Walk(func(c git.Commit) bool {
+		entry := UndoEntry{Commit: c.Hash, Subject: c.Subject()}
+		if target := trailer(c.Message, undoTrailer); target != "" {
+			undone[target] = true
+			if redoOpen && !redone[c.Hash] {
+				redo = append(redo, entry)
+			}
+			return len(undo) < maxUndo
+		}
+		if target := trailer(c.Message, redoTrailer); target != "" {
+			redone[target] = true
+		} else {
+			redoOpen = false
+		}
+		if c.Parents != 1 || trailer(c.Message, taskTrailer) == "" {
+			return false
+		}
+		if !undone[c.Hash] {
+			undo = append(undo, entry)
+		}
+		return len(undo) < maxUndo
+	})
+	return undo, redo, err
+}
+
+// Undo reverts the most recent change that has not been undone yet, as a
+// new commit. It returns the subject of the undone commit.
+func (s *Store) Undo() (string, error) {
+	undo, _, err := s.UndoStack()
+	if err != nil {
+		return "", err
+	}
+	if len(undo) == 0 {
+		return "", ErrNothingToUndo
+	}
+	e := undo[0]
+	return e.Subject, s.revert(e.Commit, "Undo", undoTrailer)
+}
+
+// Redo reverts the most recent undo, as long as nothing else changed since.
+// It returns the subject of the redone commit.
+func (s *Store) Redo() (string, error) {
+	_, redo, err := s.UndoStack()
+	if err != nil {
+		return "", err
+	}
+	if len(redo) == 0 {
+		return "", ErrNothingToRedo
+	}
+	e := redo[0]
+	subject := strings.TrimPrefix(e.Subject, "Undo: ")
+	return subject, s.revert(e.Commit, "Redo", redoTrailer)
+}
+
+// revert undoes a commit and records it with a trailer pointing at it.
+func (s *Store) revert(hash, action, trailerKey string) error {
+	if s.Locked() {
+		return ErrLocked
+	}
+	files, err := s.repo.Revert(hash)
+	if err != nil {
+		return err
+	}
+	paths := slices.Sorted(maps.Keys(files))
+	if dirty, err := s.repo.Dirty(paths...); err != nil {
+		return err
+	} else if len(dirty) > 0 {
+		return fmt.Errorf("the data dir has uncommitted changes (%s)", strings.Join(dirty, ", "))
+	}
+
+	var message string
+	err = s.repo.Walk(func(c git.Commit) bool {
+		if c.Hash != hash {
+			return true
+		}
+		subject := strings.TrimPrefix(c.Subject(), "Undo: ")
+		id := trailer(c.Message, taskTrailer)
+		if s.Encrypted() && id != "" {
+			// The commit may predate the encryption and name the task.
+			change, _, _ := strings.Cut(subject, ": ")
+			subject = change + ": " + task.ShortID(id)
+		}
+		message = fmt.Sprintf("%s: %s\n\n", action, subject)
+		if id != "" {
+			message += fmt.Sprintf("%s %s\n", taskTrailer, id)
+		}
+		if fields := trailer(c.Message, changedTrailer); fields != "" {
+			message += fmt.Sprintf("%s %s\n", changedTrailer, fields)
+		}
+		message += fmt.Sprintf("%s %s", trailerKey, hash)
+		return false
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.hookRevert(files); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		full := filepath.Join(s.dataDir, filepath.FromSlash(p))
+		if files[p] == nil {
+			if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, files[p], 0644); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.CommitPaths(message, paths...); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		s.emitFile(p)
+	}
+	return nil
+}
+
+// hookRevert runs the hooks for the tasks a revert is about to put back,
+// given the contents of the files it writes, nil for those it removes. A
+// change a hook makes to a task is written in place of the old version.
+func (s *Store) hookRevert(files map[string][]byte) error {
+	ids := map[string][]string{}
+	for p := range files {
+		if id, ok := taskID(p); ok {
+			ids[id] = append(ids[id], p)
+		}
+	}
+	for id, paths := range ids {
+		old, err := s.Load(id)
+		trashed := err != nil
+		if trashed {
+			old, _ = s.LoadTrashed(id)
+		}
+		var t *task.Task
+		var at, filename string
+		for _, p := range paths {
+			if files[p] != nil {
+				at, filename = p, filepath.Join(s.dataDir, filepath.FromSlash(p))
+				if t, err = s.decode(filename, files[p]); err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		switch {
+		case t == nil:
+			if old != nil {
+				if _, err := s.runHooks(HookDelete, old, nil); err != nil {
+					return err
+				}
+			}
+		case old != nil && !trashed && strings.HasPrefix(at, trashDir+"/"):
+			if _, err := s.runHooks(HookDelete, old, t); err != nil {
+				return err
+			}
+		default:
+			before := *t
+			if err := s.hookTask(old, t); err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(&before, t) {
+				if files[at], err = s.encode(filename, formatForFile(filename), t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// emitFile reports the current state of a task file changed behind the
+// store's back, such as by a revert.
+func (s *Store) emitFile(p string) {
+	id, ok := taskID(p)
+	if !ok {
+		return
+	}
+	if t, err := s.Load(id); err == nil {
+		s.emit(EventSaved, id, t)
+	} else if t, err := s.LoadTrashed(id); err == nil {
+		s.emit(EventTrashed, id, t)
+	} else if os.IsNotExist(err) {
+		s.emit(EventPurged, id, nil)
+	}
+}
@@ -0,0 +1,60 @@
+package task
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change is a field that differs between two versions of a task. From and
+// To are display values, empty when the field is unset.
+type Change struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Diff returns the fields that differ from old to t, in a fixed order.
+// UpdatedAt is not compared.
+func Diff(old, t *Task) []Change {
+	var changes []Change
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, Change{Field: field, From: from, To: to})
+		}
+	}
+	add("content", old.Content, t.Content)
+	add("priority", string(old.Priority), string(t.Priority))
+	add("deadline", formatDate(old.Deadline), formatDate(t.Deadline))
+	add("tags", strings.Join(old.Tags, ", "), strings.Join(t.Tags, ", "))
+	add("completed", formatTime(old.CompletedAt), formatTime(t.CompletedAt))
+	add("archived", formatBool(old.Archived), formatBool(t.Archived))
+	add("deleted", formatTime(old.DeletedAt), formatTime(t.DeletedAt))
+	sameComment := func(a, b Comment) bool { return a.Text == b.Text && a.CreatedAt.Equal(b.CreatedAt) }
+	if !slices.EqualFunc(old.Comments, t.Comments, sameComment) {
+		add("comments", strconv.Itoa(len(old.Comments)), strconv.Itoa(len(t.Comments)))
+	}
+	return changes
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return FormatDeadline(*t, "2006-01-02")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package task
+
+import (
+	"slices"
+	"time"
+)
+
+// Filter selects tasks for listing and export. The zero Filter matches
+// every task.
+type Filter struct {
+	Priority Priority
+	// Tags must all be present on the task.
+	Tags []string
+	// Done, when set, matches only completed (true) or pending (false)
+	// tasks.
+	Done *bool
+	// Overdue matches only pending tasks past their deadline.
+	Overdue bool
+	// DueBefore matches only tasks with a deadline before this time.
+	DueBefore *time.Time
+}
+
+func (f Filter) Match(t *Task) bool {
+	if f.Priority != "" && t.Priority != f.Priority {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(t.Tags, tag) {
+			return false
+		}
+	}
+	if f.Done != nil && (t.CompletedAt != nil) != *f.Done {
+		return false
+	}
+	if f.Overdue && !t.IsOverdue() {
+		return false
+	}
+	if f.DueBefore != nil && (t.Deadline == nil || !t.Deadline.Before(*f.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the tasks that match the filter, in their original order.
+func (f Filter) Apply(tasks []*Task) []*Task {
+	var matched []*Task
+	for _, t := range tasks {
+		if f.Match(t) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
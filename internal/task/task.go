@@ -15,15 +15,24 @@ const (
 )
 
 type Task struct {
-	ID          string     `json:"id"`
-	Content     string     `json:"content"`
-	Priority    Priority   `json:"priority"`
-	Deadline    *time.Time `json:"deadline,omitempty"`
-	Tags        []string   `json:"tags"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
-	Archived    bool       `json:"archived"`
+	ID          string     `json:"id" yaml:"id"`
+	Content     string     `json:"content" yaml:"-"`
+	Priority    Priority   `json:"priority" yaml:"priority"`
+	Deadline    *time.Time `json:"deadline,omitempty" yaml:"deadline,omitempty"`
+	Tags        []string   `json:"tags" yaml:"tags,flow"`
+	CreatedAt   time.Time  `json:"created_at" yaml:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" yaml:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" yaml:"completed_at,omitempty"`
+	Archived    bool       `json:"archived" yaml:"archived"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty" yaml:"archived_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+	Comments    []Comment  `json:"comments,omitempty" yaml:"comments,omitempty"`
+}
+
+// Comment is a timestamped note attached to a task.
+type Comment struct {
+	Text      string    `json:"text" yaml:"text"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
 }
 
 func New(content string) *Task {
@@ -39,6 +48,14 @@ func New(content string) *Task {
 	}
 }
 
+// ShortID returns the first eight characters of id, the way IDs are shown.
+func ShortID(id string) string {
+	if len(id) <= 8 {
+		return id
+	}
+	return id[:8]
+}
+
 func (t *Task) Complete() {
 	now := time.Now()
 	t.CompletedAt = &now
@@ -51,15 +68,35 @@ func (t *Task) Uncomplete() {
 }
 
 func (t *Task) Archive() {
+	now := time.Now()
 	t.Archived = true
-	t.UpdatedAt = time.Now()
+	t.ArchivedAt = &now
+	t.UpdatedAt = now
 }
 
 func (t *Task) Unarchive() {
 	t.Archived = false
+	t.ArchivedAt = nil
+	t.UpdatedAt = time.Now()
+}
+
+func (t *Task) Trash() {
+	now := time.Now()
+	t.DeletedAt = &now
+	t.UpdatedAt = now
+}
+
+func (t *Task) Untrash() {
+	t.DeletedAt = nil
 	t.UpdatedAt = time.Now()
 }
 
+func (t *Task) AddComment(text string) {
+	now := time.Now()
+	t.Comments = append(t.Comments, Comment{Text: text, CreatedAt: now})
+	t.UpdatedAt = now
+}
+
 func (t *Task) SetPriority(p Priority) {
 	t.Priority = p
 	t.UpdatedAt = time.Now()
@@ -70,6 +107,15 @@ func (t *Task) SetDeadline(d *time.Time) {
 	t.UpdatedAt = time.Now()
 }
 
+// FormatDeadline renders a deadline with layout, followed by the time of
+// day unless it is 23:59, the time a deadline given as a day is due.
+func FormatDeadline(d time.Time, layout string) string {
+	if d.Hour() == 23 && d.Minute() == 59 {
+		return d.Format(layout)
+	}
+	return d.Format(layout + " 15:04")
+}
+
 func (t *Task) IsOverdue() bool {
 	if t.Deadline == nil || t.CompletedAt != nil {
 		return false
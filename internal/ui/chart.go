@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+)
+
+// sparkLevels are the block characters a sparkline is drawn with, lowest
+// first.
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a row of block characters scaled to the
+// largest one. Zero is always the lowest block.
+func Sparkline(values []int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	top := max(slices.Max(values), 1)
+	var b strings.Builder
+	for _, v := range values {
+		level := v * (len(sparkLevels) - 1) / top
+		b.WriteRune(sparkLevels[max(level, 0)])
+	}
+	return b.String()
+}
+
+// Bar draws value as a horizontal bar, where top fills width cells. Any
+// value above zero gets at least a sliver.
+func Bar(value, top, width int) string {
+	if value <= 0 || top <= 0 {
+		return ""
+	}
+	eighths := value * width * 8 / top
+	full, part := eighths/8, eighths%8
+	bar := strings.Repeat("█", full)
+	if part > 0 {
+		bar += string([]rune("▏▎▍▌▋▊▉")[part-1])
+	}
+	if bar == "" {
+		bar = "▏"
+	}
+	return bar
+}
@@ -87,6 +87,11 @@ var (
 			Foreground(ColorMuted).
 			Padding(0, 2)
 
+	WarningBanner = lipgloss.NewStyle().
+			Foreground(ColorMedium).
+			Bold(true).
+			Padding(0, 2)
+
 	OverlayCard = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
 			BorderForeground(ColorPrimary).